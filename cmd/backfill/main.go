@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"osrs-price-api/internal/database"
+	"osrs-price-api/internal/models"
+	"osrs-price-api/internal/osrs"
+
+	"github.com/joho/godotenv"
+)
+
+// historyWriter is the part of the repository a backfill writes through
+type historyWriter interface {
+	BackfillPriceHistory(ctx context.Context, mode string, itemID int, points []models.TimeseriesPoint) (int64, error)
+	BackfillHourly(ctx context.Context, mode string, itemID int, points []models.TimeseriesPoint) (int64, error)
+	BackfillDaily(ctx context.Context, mode string, itemID int, points []models.TimeseriesPoint) (int64, error)
+}
+
+// backfillTargets maps each Wiki timestep to the table tier it fills
+var backfillTargets = map[string]func(historyWriter, context.Context, string, int, []models.TimeseriesPoint) (int64, error){
+	models.Timestep5m:  historyWriter.BackfillPriceHistory,
+	models.Timestep1h:  historyWriter.BackfillHourly,
+	models.Timestep24h: historyWriter.BackfillDaily,
+}
+
+func main() {
+	// Load .env file if it exists
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found, using environment variables")
+	}
+
+	var (
+		itemList  string
+		allItems  bool
+		timesteps string
+		rate      int
+		wikiURL   string
+		mode      string
+	)
+	flag.StringVar(&itemList, "items", "", "Comma-separated item IDs to backfill (e.g. 4151,11802)")
+	flag.BoolVar(&allItems, "all", false, "Backfill every item in the catalog")
+	flag.StringVar(&timesteps, "timestep", "5m,1h,24h", "Comma-separated timesteps to backfill: 5m (raw), 1h (hourly), 24h (daily)")
+	flag.IntVar(&rate, "rate", 30, "Maximum Wiki requests per minute")
+	flag.StringVar(&wikiURL, "wiki-url", "", "Override the Wiki API base URL, without the game mode feed (e.g. a local fake server)")
+	flag.StringVar(&mode, "mode", models.GameModeMain, "Game mode to backfill: main, dmm (Deadman) or fsw (Fresh Start)")
+	flag.Parse()
+
+	if itemList == "" && !allItems {
+		log.Fatal("Specify -items or -all")
+	}
+	if rate < 1 {
+		log.Fatal("-rate must be at least 1")
+	}
+
+	steps := strings.Split(timesteps, ",")
+	for i, step := range steps {
+		steps[i] = strings.TrimSpace(step)
+		if _, ok := backfillTargets[steps[i]]; !ok {
+			log.Fatalf("Unsupported timestep: %s (available: 5m, 1h, 24h)", steps[i])
+		}
+	}
+
+	// Load database configuration
+	dbConfig := database.LoadConfig()
+
+	// Connect to database
+	db, err := database.Connect(dbConfig)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		log.Fatalf("Failed to get database instance: %v", err)
+	}
+	defer sqlDB.Close()
+
+	repo := database.NewRepository(db, dbConfig.Storage)
+
+	// Ctrl-C or SIGTERM aborts the in-flight request and stops the backfill
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	sourceConfig := osrs.LoadConfig()
+	if wikiURL != "" {
+		sourceConfig.Source = osrs.SourceWiki
+		sourceConfig.WikiURL = wikiURL
+	}
+	source, err := osrs.NewSource(sourceConfig, mode)
+	if err != nil {
+		log.Fatalf("Failed to create price source: %v", err)
+	}
+
+	itemIDs, err := resolveItems(ctx, itemList, allItems, repo, source)
+	if err != nil {
+		log.Fatalf("Failed to resolve items: %v", err)
+	}
+
+	log.Printf("Backfilling %d %s items for timesteps %s (max %d requests/min)", len(itemIDs), mode, strings.Join(steps, ", "), rate)
+
+	// Pace timeseries requests to respect the Wiki's usage guidelines
+	total, failures := backfill(ctx, source, repo, mode, itemIDs, steps, time.Minute/time.Duration(rate))
+
+	log.Printf("✓ Backfill completed: %d rows inserted, %d failed requests", total, failures)
+}
+
+// backfill fetches every timestep of every item, at most one request per
+// interval, and writes the points to their tiers; it returns how many rows
+// were inserted and how many requests failed
+func backfill(ctx context.Context, source osrs.PriceSource, writer historyWriter, mode string, itemIDs []int, steps []string, interval time.Duration) (int64, int) {
+	limiter := time.NewTicker(interval)
+	defer limiter.Stop()
+
+	var total int64
+	failures := 0
+items:
+	for i, itemID := range itemIDs {
+		for _, step := range steps {
+			select {
+			case <-limiter.C:
+			case <-ctx.Done():
+				log.Printf("Backfill interrupted after %d/%d items", i, len(itemIDs))
+				break items
+			}
+
+			points, err := source.GetTimeseries(ctx, itemID, step)
+			if err != nil {
+				log.Printf("Error fetching %s timeseries for item %d: %v", step, itemID, err)
+				failures++
+				continue
+			}
+
+			inserted, err := backfillTargets[step](writer, ctx, mode, itemID, withPrices(points))
+			if err != nil {
+				log.Printf("Error saving %s timeseries for item %d: %v", step, itemID, err)
+				failures++
+				continue
+			}
+			total += inserted
+		}
+
+		if (i+1)%100 == 0 {
+			log.Printf("Progress: %d/%d items, %d rows inserted", i+1, len(itemIDs), total)
+		}
+	}
+	return total, failures
+}
+
+// resolveItems returns the item IDs to backfill, fetching the catalog if it is empty
+func resolveItems(ctx context.Context, itemList string, allItems bool, repo *database.Repository, source osrs.PriceSource) ([]int, error) {
+	if !allItems {
+		var ids []int
+		for _, s := range strings.Split(itemList, ",") {
+			id, err := strconv.Atoi(strings.TrimSpace(s))
+			if err != nil {
+				return nil, err
+			}
+			ids = append(ids, id)
+		}
+		return ids, nil
+	}
+
+	mapping, err := repo.GetItemMappings(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(mapping) == 0 {
+		log.Println("Item catalog is empty, fetching mapping from OSRS Wiki API...")
+		items, err := source.GetMapping(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := repo.SaveItemMappings(ctx, items); err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			mapping[item.ID] = item
+		}
+	}
+
+	ids := make([]int, 0, len(mapping))
+	for id := range mapping {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+// withPrices drops windows in which the item did not trade at all
+func withPrices(points []models.TimeseriesPoint) []models.TimeseriesPoint {
+	filtered := points[:0]
+	for _, p := range points {
+		if p.AvgHighPrice > 0 || p.AvgLowPrice > 0 {
+			filtered = append(filtered, p)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"osrs-price-api/internal/database"
+	"osrs-price-api/internal/models"
+	"osrs-price-api/internal/osrs"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// seriesStart is the first window the fake Wiki reports, far enough in the
+// past that it never mixes with live data
+var seriesStart = time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+
+var stepDurations = map[string]time.Duration{
+	models.Timestep5m:  5 * time.Minute,
+	models.Timestep1h:  time.Hour,
+	models.Timestep24h: 24 * time.Hour,
+}
+
+// fakeWiki serves /timeseries with three windows per item and timestep, the
+// last of which has no trades, and records when each request arrived
+type fakeWiki struct {
+	mu       sync.Mutex
+	requests []time.Time
+}
+
+func (f *fakeWiki) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.requests = append(f.requests, time.Now())
+	f.mu.Unlock()
+
+	if r.URL.Path != "/osrs/timeseries" {
+		http.NotFound(w, r)
+		return
+	}
+	itemID, err := strconv.Atoi(r.URL.Query().Get("id"))
+	step, ok := stepDurations[r.URL.Query().Get("timestep")]
+	if err != nil || !ok {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	resp := models.TimeseriesResponse{ItemID: itemID}
+	for i := 0; i < 3; i++ {
+		point := models.TimeseriesPoint{Timestamp: seriesStart.Add(time.Duration(i) * step).Unix()}
+		if i < 2 {
+			point.AvgHighPrice, point.AvgLowPrice = int64(1000+i), int64(900+i)
+			point.HighPriceVolume, point.LowPriceVolume = 5, 7
+		}
+		resp.Data = append(resp.Data, point)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (f *fakeWiki) requestTimes() []time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]time.Time(nil), f.requests...)
+}
+
+// newFakeSource starts a fake Wiki and returns a client for it
+func newFakeSource(t *testing.T) (*fakeWiki, osrs.PriceSource) {
+	t.Helper()
+	wiki := &fakeWiki{}
+	server := httptest.NewServer(wiki)
+	t.Cleanup(server.Close)
+
+	options := osrs.DefaultClientOptions()
+	options.Governor = osrs.NewGovernor(60000)
+	source, err := osrs.NewClientForMode(server.URL, models.GameModeMain, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return wiki, source
+}
+
+// memoryWriter keeps backfilled points in memory, skipping windows it already has
+type memoryWriter struct {
+	rows map[string]bool
+}
+
+func (m *memoryWriter) write(tier, mode string, itemID int, points []models.TimeseriesPoint) (int64, error) {
+	var inserted int64
+	for _, p := range points {
+		key := tier + "/" + mode + "/" + strconv.Itoa(itemID) + "/" + strconv.FormatInt(p.Timestamp, 10)
+		if !m.rows[key] {
+			m.rows[key] = true
+			inserted++
+		}
+	}
+	return inserted, nil
+}
+
+func (m *memoryWriter) BackfillPriceHistory(ctx context.Context, mode string, itemID int, points []models.TimeseriesPoint) (int64, error) {
+	return m.write("raw", mode, itemID, points)
+}
+
+func (m *memoryWriter) BackfillHourly(ctx context.Context, mode string, itemID int, points []models.TimeseriesPoint) (int64, error) {
+	return m.write("hourly", mode, itemID, points)
+}
+
+func (m *memoryWriter) BackfillDaily(ctx context.Context, mode string, itemID int, points []models.TimeseriesPoint) (int64, error) {
+	return m.write("daily", mode, itemID, points)
+}
+
+func TestBackfillPacesRequests(t *testing.T) {
+	wiki, source := newFakeSource(t)
+	writer := &memoryWriter{rows: map[string]bool{}}
+	steps := []string{models.Timestep5m, models.Timestep1h}
+	interval := 25 * time.Millisecond
+
+	started := time.Now()
+	total, failures := backfill(context.Background(), source, writer, models.GameModeMain, []int{4151, 11802}, steps, interval)
+	if failures != 0 {
+		t.Fatalf("%d requests failed", failures)
+	}
+	// Two items, two timesteps, two windows with trades each
+	if total != 8 {
+		t.Errorf("inserted %d rows, want 8", total)
+	}
+
+	requests := wiki.requestTimes()
+	if len(requests) != 4 {
+		t.Fatalf("made %d requests, want 4", len(requests))
+	}
+	// Request n waits for tick n, so the last one can't come before 4 intervals
+	if elapsed := requests[len(requests)-1].Sub(started); elapsed < 4*interval {
+		t.Errorf("4 requests took %s, want at least %s", elapsed, 4*interval)
+	}
+}
+
+func TestBackfillStopsWhenCancelled(t *testing.T) {
+	wiki, source := newFakeSource(t)
+	writer := &memoryWriter{rows: map[string]bool{}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	total, failures := backfill(ctx, source, writer, models.GameModeMain, []int{4151}, []string{models.Timestep5m}, time.Hour)
+	if total != 0 || failures != 0 || len(wiki.requestTimes()) != 0 {
+		t.Errorf("cancelled backfill inserted %d rows, failed %d requests and made %d", total, failures, len(wiki.requestTimes()))
+	}
+}
+
+// TestBackfillIsIdempotent backfills every tier into a scratch database twice
+// and expects the second run to insert nothing
+// It needs DATABASE_URL to point at a local database it may write to.
+func TestBackfillIsIdempotent(t *testing.T) {
+	if os.Getenv("DATABASE_URL") == "" {
+		t.Skip("DATABASE_URL not set")
+	}
+
+	dbConfig := database.LoadConfig()
+	db, err := database.Connect(dbConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db = db.Session(&gorm.Session{Logger: logger.Discard})
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	ctx := context.Background()
+	if err := database.Migrate(ctx, db, "../../migrations"); err != nil {
+		t.Fatal(err)
+	}
+	if dbConfig.Storage == database.StorageTimescale {
+		if err := database.ConvertToTimescale(ctx, db); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := database.SetupStorage(ctx, db, dbConfig.Storage); err != nil {
+		t.Fatal(err)
+	}
+
+	itemIDs := []int{1_000_001, 1_000_002}
+	cleanup := func() {
+		tables := []string{"price_history", "price_history_hourly", "price_history_daily"}
+		if dbConfig.Storage == database.StorageTimescale {
+			tables = []string{"price_history", "price_history_hourly_hypertable", "price_history_daily_base"}
+		}
+		for _, table := range tables {
+			if err := db.Exec("DELETE FROM "+table+" WHERE item_id IN ?", itemIDs).Error; err != nil {
+				t.Errorf("cleaning up %s: %v", table, err)
+			}
+		}
+	}
+	cleanup()
+	t.Cleanup(cleanup)
+
+	_, source := newFakeSource(t)
+	repo := database.NewRepository(db, dbConfig.Storage)
+	steps := []string{models.Timestep5m, models.Timestep1h, models.Timestep24h}
+
+	total, failures := backfill(ctx, source, repo, models.GameModeMain, itemIDs, steps, time.Millisecond)
+	if failures != 0 {
+		t.Fatalf("first run: %d requests failed", failures)
+	}
+	// Two items, three tiers, two windows with trades each
+	if total != 12 {
+		t.Errorf("first run inserted %d rows, want 12", total)
+	}
+
+	total, failures = backfill(ctx, source, repo, models.GameModeMain, itemIDs, steps, time.Millisecond)
+	if failures != 0 {
+		t.Fatalf("second run: %d requests failed", failures)
+	}
+	if total != 0 {
+		t.Errorf("second run inserted %d rows, want 0", total)
+	}
+}
@@ -1,27 +1,63 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"strconv"
 
 	"osrs-price-api/internal/database"
-	
+
 	"github.com/joho/godotenv"
 )
 
+const usage = `Usage: migrate -command=<command> [argument]
+
+Commands:
+  up               Apply every pending migration
+  down [N]         Roll back the last N applied migrations (default 1)
+  goto <version>   Migrate up or down to the given version (0 rolls back everything)
+  status           List migrations and whether they are applied
+  create <name>    Scaffold NNN_<name>.sql and NNN_<name>.down.sql
+  reset            Roll back every migration and apply them again (deletes all data)
+  timescale        Apply pending migrations, then convert the database for
+                   DB_STORAGE=timescaledb while servers keep running (resumable)
+`
+
 func main() {
 	// Load .env file if it exists
 	if err := godotenv.Load(); err != nil {
 		log.Println("No .env file found, using environment variables")
 	}
-	var command string
-	flag.StringVar(&command, "command", "up", "Migration command: up, down, status, create")
+	var command, dir string
+	flag.StringVar(&command, "command", "up", "Migration command: up, down, goto, status, create, reset, timescale")
+	flag.StringVar(&dir, "dir", "", "Migrations directory (default: MIGRATIONS_DIR or ./migrations)")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
 	flag.Parse()
 
 	// Load database configuration
 	dbConfig := database.LoadConfig()
+	if dir == "" {
+		dir = dbConfig.MigrationsDir
+	}
+
+	// Scaffolding only touches the filesystem
+	if command == "create" {
+		if flag.NArg() != 1 {
+			log.Fatal("Usage: migrate -command=create <name>")
+		}
+		upFile, downFile, err := database.NewMigrator(nil, dir).Create(flag.Arg(0))
+		if err != nil {
+			log.Fatalf("Create failed: %v", err)
+		}
+		log.Printf("✓ Created %s and %s", upFile, downFile)
+		return
+	}
 
 	// Connect to database
 	db, err := database.Connect(dbConfig)
@@ -35,19 +71,56 @@ func main() {
 	}
 	defer sqlDB.Close()
 
+	migrator := database.NewMigrator(sqlDB, dir)
+	ctx := context.Background()
+
 	switch command {
 	case "up":
 		log.Println("Running migrations...")
-		if err := database.AutoMigrate(db); err != nil {
+		applied, err := migrator.Up(ctx)
+		if err != nil {
 			log.Fatalf("Migration failed: %v", err)
 		}
-		log.Println("✓ Migrations completed successfully")
+		log.Printf("✓ Migrations completed successfully (%d applied)", applied)
+
+	case "down":
+		steps := 1
+		if flag.NArg() > 0 {
+			steps = intArg("down")
+		}
+		rolledBack, err := migrator.Down(ctx, steps)
+		if err != nil {
+			log.Fatalf("Rollback failed: %v", err)
+		}
+		log.Printf("✓ Rolled back %d migrations", rolledBack)
+
+	case "goto":
+		if flag.NArg() != 1 {
+			log.Fatal("Usage: migrate -command=goto <version>")
+		}
+		changed, err := migrator.Goto(ctx, intArg("goto"))
+		if err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		log.Printf("✓ Now at version %s (%d migrations run)", flag.Arg(0), changed)
 
 	case "status":
-		log.Println("Checking migration status...")
-		if err := database.MigrationStatus(db); err != nil {
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
 			log.Fatalf("Failed to check status: %v", err)
 		}
+		for _, s := range statuses {
+			switch {
+			case s.Missing:
+				fmt.Printf("! %03d_%s  applied %s, file missing\n", s.Version, s.Name, s.AppliedAt.Format("2006-01-02 15:04"))
+			case s.Modified:
+				fmt.Printf("! %03d_%s  applied %s, file modified since\n", s.Version, s.Name, s.AppliedAt.Format("2006-01-02 15:04"))
+			case s.Applied:
+				fmt.Printf("✓ %03d_%s  applied %s\n", s.Version, s.Name, s.AppliedAt.Format("2006-01-02 15:04"))
+			default:
+				fmt.Printf("✗ %03d_%s  pending\n", s.Version, s.Name)
+			}
+		}
 
 	case "reset":
 		log.Println("WARNING: This will drop all tables and recreate them!")
@@ -58,13 +131,35 @@ func main() {
 			log.Println("Reset cancelled")
 			os.Exit(0)
 		}
-		
-		if err := database.ResetDatabase(db); err != nil {
+
+		if _, err := migrator.Goto(ctx, 0); err != nil {
+			log.Fatalf("Reset failed: %v", err)
+		}
+		if _, err := migrator.Up(ctx); err != nil {
 			log.Fatalf("Reset failed: %v", err)
 		}
 		log.Println("✓ Database reset completed successfully")
 
+	case "timescale":
+		if _, err := migrator.Up(ctx); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		log.Println("Converting the database to TimescaleDB...")
+		if err := database.ConvertToTimescale(ctx, db); err != nil {
+			log.Fatalf("Conversion failed: %v", err)
+		}
+		log.Println("✓ Converted; restart the servers with DB_STORAGE=timescaledb")
+
 	default:
-		log.Fatalf("Unknown command: %s (available: up, status, reset)", command)
+		log.Fatalf("Unknown command: %s (available: up, down, goto, status, create, reset, timescale)", command)
+	}
+}
+
+// intArg parses the command's numeric argument
+func intArg(command string) int {
+	n, err := strconv.Atoi(flag.Arg(0))
+	if err != nil || n < 0 {
+		log.Fatalf("Invalid argument for %s: %q", command, flag.Arg(0))
 	}
-}
\ No newline at end of file
+	return n
+}
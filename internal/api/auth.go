@@ -0,0 +1,37 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminAuthMiddleware guards admin endpoints with the ADMIN_TOKEN bearer token
+// Admin endpoints are disabled entirely while ADMIN_TOKEN is unset.
+func AdminAuthMiddleware() gin.HandlerFunc {
+	token := os.Getenv("ADMIN_TOKEN")
+
+	return func(c *gin.Context) {
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error":   "Admin endpoints disabled",
+				"message": "Set ADMIN_TOKEN to enable admin endpoints",
+			})
+			return
+		}
+
+		provided := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error":   "Unauthorized",
+				"message": "Send the admin token as Authorization: Bearer <token>",
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -1,69 +1,134 @@
 package api
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"osrs-price-api/internal/cache"
 	"osrs-price-api/internal/database"
+	"osrs-price-api/internal/models"
 	"osrs-price-api/internal/osrs"
+	"osrs-price-api/internal/spool"
+	"osrs-price-api/internal/worker"
 
 	"github.com/gin-gonic/gin"
 )
 
+// queryTimeout bounds the database work done for a single request
+const queryTimeout = 15 * time.Second
+
+// Candle request limits: the default range and the most buckets one request may return
+const (
+	defaultCandles = 200
+	maxCandles     = 5000
+)
+
 // Handler handles HTTP requests
 type Handler struct {
-	osrsClient *osrs.Client
+	sources    map[string]osrs.PriceSource
 	cache      *cache.PriceCache
 	repository *database.Repository
+	scheduler  *worker.Scheduler
+	spools     map[string]*spool.Spool
 }
 
 // NewHandler creates a new API handler
-func NewHandler(client *osrs.Client, cache *cache.PriceCache, repo *database.Repository) *Handler {
+// sources holds one price source per tracked game mode; handlers only use it to
+// validate ?mode= and report upstream health, never to fetch prices
+// spools holds each game mode's snapshot spool, for reporting its backlog.
+func NewHandler(sources map[string]osrs.PriceSource, cache *cache.PriceCache, repo *database.Repository, scheduler *worker.Scheduler, spools map[string]*spool.Spool) *Handler {
 	return &Handler{
-		osrsClient: client,
+		sources:    sources,
 		cache:      cache,
 		repository: repo,
+		scheduler:  scheduler,
+		spools:     spools,
+	}
+}
+
+// queryContext returns a context that ends when the client disconnects or
+// queryTimeout elapses, whichever comes first
+func queryContext(c *gin.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(c.Request.Context(), queryTimeout)
+}
+
+// gameMode reads the ?mode= query parameter (default: main game)
+// Responds with 400 and returns false if the mode is unknown or not tracked
+func (h *Handler) gameMode(c *gin.Context) (string, bool) {
+	mode := c.DefaultQuery("mode", models.GameModeMain)
+	if _, tracked := h.sources[mode]; !tracked {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid game mode",
+			"message": "Mode must be one of the tracked game modes: " + h.trackedModes(),
+		})
+		return "", false
+	}
+	return mode, true
+}
+
+// trackedModes lists the tracked game modes in a stable order
+func (h *Handler) trackedModes() string {
+	var modes []string
+	for _, mode := range models.GameModes {
+		if _, tracked := h.sources[mode]; tracked {
+			modes = append(modes, mode)
+		}
 	}
+	return strings.Join(modes, ", ")
 }
 
 // HealthCheck handles health check requests
 func (h *Handler) HealthCheck(c *gin.Context) {
+	// Report each Wiki client's circuit breaker so outages are visible
+	breakers := gin.H{}
+	var governor interface{}
+	for mode, source := range h.sources {
+		if client, ok := source.(*osrs.Client); ok {
+			breakers[mode] = client.BreakerState()
+			// Every client shares the same governor, so any of them reports it
+			governor = client.GovernorStats()
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"status": "ok",
-		"service": "osrs-price-api",
+		"status":       "ok",
+		"service":      "osrs-price-api",
+		"upstream":     breakers,
+		"rate_limiter": governor,
+		"leader":       h.scheduler.Leading(), // Whether this instance runs the background jobs
 	})
 }
 
 // GetAllPrices returns all item prices
+// Prices are served from the snapshot the price fetcher pushes into the cache,
+// falling back to the newest snapshot in the database; the Wiki is never called here
 func (h *Handler) GetAllPrices(c *gin.Context) {
-	// Check cache first
-	if prices, found := h.cache.GetAll(); found {
-		c.JSON(http.StatusOK, gin.H{
-			"data":   prices,
-			"cached": true,
-		})
+	mode, ok := h.gameMode(c)
+	if !ok {
 		return
 	}
 
-	// Fetch from API if not cached
-	prices, err := h.osrsClient.GetLatestPrices()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to fetch prices",
-			"message": err.Error(),
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	snapshot, cached, found := h.latestPrices(ctx, mode)
+	if !found {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":   "Prices temporarily unavailable",
+			"message": "No prices have been fetched from the OSRS Wiki yet",
 		})
 		return
 	}
 
-	// Cache the results
-	h.cache.SetAll(prices)
-
-	c.JSON(http.StatusOK, gin.H{
-		"data":   prices,
-		"cached": false,
-	})
+	response := snapshotResponse(snapshot, mode, cached)
+	response["data"] = snapshot.Prices
+	c.JSON(http.StatusOK, response)
 }
 
 // GetItemPrice returns the price for a specific item
@@ -79,53 +144,321 @@ func (h *Handler) GetItemPrice(c *gin.Context) {
 		return
 	}
 
-	// Check cache first
-	if price, found := h.cache.Get(itemID); found {
-		price.ID, _ = strconv.Atoi(itemID)
-		c.JSON(http.StatusOK, gin.H{
-			"data":   price,
-			"cached": true,
+	mode, ok := h.gameMode(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	snapshot, cached, found := h.latestPrices(ctx, mode)
+	if !found {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":   "Price temporarily unavailable",
+			"message": "No prices have been fetched from the OSRS Wiki yet",
+		})
+		return
+	}
+
+	price, exists := snapshot.Prices[itemID]
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Item not found",
+			"message": "No price data available for this item ID",
 		})
 		return
 	}
 
-	// Fetch from API if not cached
-	price, err := h.osrsClient.GetItemPrice(itemID)
+	response := snapshotResponse(snapshot, mode, cached)
+	response["data"] = price
+	if item, hasItem := h.itemMappings(ctx)[price.ID]; hasItem {
+		response["item"] = item
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// snapshotResponse describes where served prices come from: when they were
+// fetched, whether they are stale and the database snapshot they were saved
+// as (omitted until the price fetcher has saved them)
+func snapshotResponse(snapshot cache.Snapshot, mode string, cached bool) gin.H {
+	age := time.Since(snapshot.FetchedAt)
+	response := gin.H{
+		"mode":        mode,
+		"cached":      cached,
+		"stale":       age > cache.StaleAfter,
+		"as_of":       snapshot.FetchedAt,
+		"age_seconds": int64(age.Seconds()),
+	}
+	if snapshot.SnapshotID != 0 {
+		response["snapshot_id"] = snapshot.SnapshotID
+	}
+	return response
+}
+
+// latestPrices returns the newest price snapshot for a game mode, from the cache
+// if the price fetcher has filled it, otherwise from the database
+func (h *Handler) latestPrices(ctx context.Context, mode string) (snapshot cache.Snapshot, cached, found bool) {
+	if snapshot, found := h.cache.GetAll(mode); found {
+		return snapshot, true, true
+	}
+
+	prices, fetchedAt, snapshotID, err := h.repository.GetLatestSnapshot(ctx, mode)
+	if err != nil {
+		log.Printf("Error loading last %s snapshot: %v", mode, err)
+		return cache.Snapshot{}, false, false
+	}
+	if len(prices) == 0 {
+		return cache.Snapshot{}, false, false
+	}
+
+	// Keep the database snapshot until the price fetcher pushes a newer one
+	models.ApplyItemNames(prices, h.itemMappings(ctx))
+	snapshot = cache.Snapshot{Prices: prices, FetchedAt: fetchedAt, SnapshotID: snapshotID}
+	h.cache.SetAll(mode, snapshot)
+	return snapshot, false, true
+}
+
+// itemMappings returns the item catalog, loading it from the database on a cache miss
+func (h *Handler) itemMappings(ctx context.Context) map[int]models.ItemMapping {
+	if mapping, found := h.cache.GetItemMappings(); found {
+		return mapping
+	}
+
+	mapping, err := h.repository.GetItemMappings(ctx)
+	if err != nil {
+		log.Printf("Error loading item mapping: %v", err)
+		return nil
+	}
+
+	// Don't cache an empty catalog so names appear as soon as the worker fills it
+	if len(mapping) > 0 {
+		h.cache.SetItemMappings(mapping)
+	}
+	return mapping
+}
+
+// ClearCache clears all cached data
+func (h *Handler) ClearCache(c *gin.Context) {
+	h.cache.Clear()
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Cache cleared successfully",
+	})
+}
+
+// GetCleanupConfig returns the retention setting of every tier
+func (h *Handler) GetCleanupConfig(c *gin.Context) {
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	configs, err := h.repository.GetCleanupConfigs(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to fetch cleanup config",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  configs,
+		"count": len(configs),
+	})
+}
+
+// cleanupConfigUpdate is the body of UpdateCleanupConfig
+type cleanupConfigUpdate struct {
+	RetentionDays *int  `json:"retention_days" binding:"required,min=0"`
+	Enabled       *bool `json:"enabled" binding:"required"`
+}
+
+// UpdateCleanupConfig changes a tier's retention; it applies from the next cleanup run
+func (h *Handler) UpdateCleanupConfig(c *gin.Context) {
+	tier := c.Param("tier")
+	if !models.IsValidResolution(tier) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid tier",
+			"message": "Tier must be raw, hourly, daily, weekly or monthly",
+		})
+		return
+	}
+
+	var body cleanupConfigUpdate
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"message": "Expected {\"retention_days\": <days, 0 keeps forever>, \"enabled\": <bool>}",
+		})
+		return
+	}
+
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	config, err := h.repository.UpdateCleanupConfig(ctx, tier, *body.RetentionDays, *body.Enabled)
 	if err != nil {
-		if err.Error() == "item not found" {
-			c.JSON(http.StatusNotFound, gin.H{
-				"error": "Item not found",
-				"message": "No price data available for this item ID",
-			})
-			return
-		}
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to fetch price",
+			"error":   "Failed to update cleanup config",
 			"message": err.Error(),
 		})
 		return
 	}
+	if config == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Tier not configured",
+			"message": "No cleanup config row exists for this tier",
+		})
+		return
+	}
 
-	// Cache the result
-	h.cache.Set(itemID, *price)
+	log.Printf("Cleanup config for %s updated: retention %d days, enabled %t", tier, config.RetentionDays, config.Enabled)
+	c.JSON(http.StatusOK, config)
+}
 
-	// Add ID to response
-	price.ID, _ = strconv.Atoi(itemID)
+// GetCleanupLogs returns the most recent cleanup and rollup runs
+func (h *Handler) GetCleanupLogs(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 500 {
+		limit = 50
+	}
+
+	job := c.Query("job")
+	if job != "" && job != models.CleanupJobCleanup && job != models.CleanupJobRollup {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid job",
+			"message": "Job must be cleanup or rollup",
+		})
+		return
+	}
+
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	logs, err := h.repository.GetCleanupLogs(ctx, job, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to fetch cleanup logs",
+			"message": err.Error(),
+		})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"data":   price,
-		"cached": false,
+		"data":  logs,
+		"count": len(logs),
 	})
 }
 
-// ClearCache clears all cached data
-func (h *Handler) ClearCache(c *gin.Context) {
-	h.cache.Clear()
+// GetFetchRuns returns the most recent price fetches, including failed ones
+// mode is optional; without it every game mode is listed.
+func (h *Handler) GetFetchRuns(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 500 {
+		limit = 50
+	}
+
+	mode := c.Query("mode")
+	if _, tracked := h.sources[mode]; mode != "" && !tracked {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid game mode",
+			"message": "Mode must be one of the tracked game modes: " + h.trackedModes(),
+		})
+		return
+	}
+
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	runs, err := h.repository.GetFetchRuns(ctx, mode, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to load fetch runs",
+			"message": err.Error(),
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Cache cleared successfully",
+		"data":  runs,
+		"count": len(runs),
+	})
+}
+
+// GetJobs returns the schedule and last/next run of every background job
+func (h *Handler) GetJobs(c *gin.Context) {
+	jobs := h.scheduler.Status()
+	c.JSON(http.StatusOK, gin.H{
+		"leader": h.scheduler.Leading(),
+		"data":   jobs,
+		"count":  len(jobs),
+	})
+}
+
+// GetSpool returns the backlog of snapshots waiting in each game mode's spool
+// The spool is local to an instance; only the leader fetches, so it is the
+// one whose backlog matters.
+func (h *Handler) GetSpool(c *gin.Context) {
+	var spools []spool.Stats
+	total := 0
+	for _, mode := range models.GameModes {
+		s, ok := h.spools[mode]
+		if !ok {
+			continue
+		}
+		stats, err := s.Stats()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to read spool",
+				"message": err.Error(),
+			})
+			return
+		}
+		spools = append(spools, stats)
+		total += stats.Entries
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"leader":  h.scheduler.Leading(),
+		"data":    spools,
+		"backlog": total,
 	})
 }
 
+// RunJob starts a background job right away, outside its schedule
+func (h *Handler) RunJob(c *gin.Context) {
+	name := c.Param("name")
+
+	err := h.scheduler.Trigger(name)
+	switch {
+	case errors.Is(err, worker.ErrUnknownJob):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Job not found",
+			"message": err.Error(),
+		})
+	case errors.Is(err, worker.ErrJobRunning):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Job already running",
+			"message": err.Error(),
+		})
+	case errors.Is(err, worker.ErrNotLeader):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Not the leader",
+			"message": "Only the leader instance runs background jobs; send the request to it (see leader in /health)",
+		})
+	case err != nil:
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":   "Job not started",
+			"message": err.Error(),
+		})
+	default:
+		log.Printf("Job %s triggered on demand", name)
+		c.JSON(http.StatusAccepted, gin.H{
+			"message": "Job started",
+			"job":     name,
+		})
+	}
+}
+
 // GetPriceHistory returns historical price data for an item
 func (h *Handler) GetPriceHistory(c *gin.Context) {
 	itemID, err := strconv.Atoi(c.Param("id"))
@@ -137,6 +470,11 @@ func (h *Handler) GetPriceHistory(c *gin.Context) {
 		return
 	}
 
+	mode, ok := h.gameMode(c)
+	if !ok {
+		return
+	}
+
 	// Parse time range parameters
 	hoursStr := c.DefaultQuery("hours", "24")
 	hours, err := strconv.Atoi(hoursStr)
@@ -147,7 +485,20 @@ func (h *Handler) GetPriceHistory(c *gin.Context) {
 	endTime := time.Now().UTC()
 	startTime := endTime.Add(-time.Duration(hours) * time.Hour)
 
-	history, err := h.repository.GetPriceHistory(itemID, startTime, endTime)
+	// Pick the resolution from the requested span unless one is asked for
+	resolution := c.DefaultQuery("resolution", models.ResolutionForSpan(endTime.Sub(startTime)))
+	if !models.IsValidResolution(resolution) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid resolution",
+			"message": "Resolution must be raw, hourly, daily, weekly or monthly",
+		})
+		return
+	}
+
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	history, err := h.repository.GetPriceHistory(ctx, mode, itemID, resolution, startTime, endTime)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to fetch price history",
@@ -158,6 +509,8 @@ func (h *Handler) GetPriceHistory(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"item_id":    itemID,
+		"mode":       mode,
+		"resolution": resolution,
 		"start_time": startTime,
 		"end_time":   endTime,
 		"data":       history,
@@ -165,6 +518,154 @@ func (h *Handler) GetPriceHistory(c *gin.Context) {
 	})
 }
 
+// GetPriceAverages returns 5-minute or 1-hour average prices and volumes for an item
+func (h *Handler) GetPriceAverages(c *gin.Context) {
+	itemID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid item ID",
+			"message": "Item ID must be a number",
+		})
+		return
+	}
+
+	mode, ok := h.gameMode(c)
+	if !ok {
+		return
+	}
+
+	timestep := c.DefaultQuery("timestep", models.Timestep5m)
+	if timestep != models.Timestep5m && timestep != models.Timestep1h {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid timestep",
+			"message": "Timestep must be 5m or 1h",
+		})
+		return
+	}
+
+	// Parse time range parameters
+	hoursStr := c.DefaultQuery("hours", "24")
+	hours, err := strconv.Atoi(hoursStr)
+	if err != nil || hours < 1 {
+		hours = 24
+	}
+
+	endTime := time.Now().UTC()
+	startTime := endTime.Add(-time.Duration(hours) * time.Hour)
+
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	averages, err := h.repository.GetPriceAverages(ctx, mode, itemID, timestep, startTime, endTime)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to fetch price averages",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"item_id":    itemID,
+		"mode":       mode,
+		"timestep":   timestep,
+		"start_time": startTime,
+		"end_time":   endTime,
+		"data":       averages,
+		"count":      len(averages),
+	})
+}
+
+// GetCandles returns OHLC candles for both the high and low price of an item
+func (h *Handler) GetCandles(c *gin.Context) {
+	itemID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid item ID",
+			"message": "Item ID must be a number",
+		})
+		return
+	}
+
+	mode, ok := h.gameMode(c)
+	if !ok {
+		return
+	}
+
+	interval := c.DefaultQuery("interval", "1h")
+	step, ok := models.CandleIntervals[interval]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid interval",
+			"message": "Interval must be 5m, 15m, 1h, 4h, 1d or 1w",
+		})
+		return
+	}
+
+	// Parse time range parameters (Unix seconds or RFC 3339; default: the last 200 candles)
+	endTime := time.Now().UTC()
+	if value := c.Query("end"); value != "" {
+		if endTime, err = parseTime(value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid end time",
+				"message": "end must be Unix seconds or an RFC 3339 timestamp",
+			})
+			return
+		}
+	}
+	startTime := endTime.Add(-defaultCandles * step)
+	if value := c.Query("start"); value != "" {
+		if startTime, err = parseTime(value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid start time",
+				"message": "start must be Unix seconds or an RFC 3339 timestamp",
+			})
+			return
+		}
+	}
+
+	// Start on a bucket boundary so the first candle is complete
+	startTime = startTime.Truncate(step)
+	if !startTime.Before(endTime) || endTime.Sub(startTime)/step > maxCandles {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid time range",
+			"message": fmt.Sprintf("start must be before end and the range may span at most %d candles", maxCandles),
+		})
+		return
+	}
+
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	candles, err := h.repository.GetCandles(ctx, mode, itemID, interval, startTime, endTime)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to fetch candles",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"item_id":    itemID,
+		"mode":       mode,
+		"interval":   interval,
+		"start_time": startTime,
+		"end_time":   endTime,
+		"data":       candles,
+		"count":      len(candles),
+	})
+}
+
+// parseTime parses a query parameter given as Unix seconds or an RFC 3339 timestamp
+func parseTime(value string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	return t.UTC(), err
+}
+
 // GetPriceChange returns price change data for an item
 func (h *Handler) GetPriceChange(c *gin.Context) {
 	itemID, err := strconv.Atoi(c.Param("id"))
@@ -176,6 +677,11 @@ func (h *Handler) GetPriceChange(c *gin.Context) {
 		return
 	}
 
+	mode, ok := h.gameMode(c)
+	if !ok {
+		return
+	}
+
 	// Parse duration parameter (default 24 hours)
 	hoursStr := c.DefaultQuery("hours", "24")
 	hours, err := strconv.Atoi(hoursStr)
@@ -184,7 +690,10 @@ func (h *Handler) GetPriceChange(c *gin.Context) {
 	}
 
 	duration := time.Duration(hours) * time.Hour
-	change, err := h.repository.GetPriceChange(itemID, duration)
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	change, err := h.repository.GetPriceChange(ctx, mode, itemID, duration)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to fetch price change",
@@ -209,6 +718,11 @@ func (h *Handler) GetPriceStats(c *gin.Context) {
 		return
 	}
 
+	mode, ok := h.gameMode(c)
+	if !ok {
+		return
+	}
+
 	// Parse time range parameters
 	hoursStr := c.DefaultQuery("hours", "168") // Default 7 days
 	hours, err := strconv.Atoi(hoursStr)
@@ -219,7 +733,10 @@ func (h *Handler) GetPriceStats(c *gin.Context) {
 	endTime := time.Now().UTC()
 	startTime := endTime.Add(-time.Duration(hours) * time.Hour)
 
-	stats, err := h.repository.GetPriceStats(itemID, startTime, endTime)
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	stats, err := h.repository.GetPriceStats(ctx, mode, itemID, startTime, endTime)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to fetch price statistics",
@@ -235,6 +752,11 @@ func (h *Handler) GetPriceStats(c *gin.Context) {
 
 // GetTopGainers returns items with the highest price increases
 func (h *Handler) GetTopGainers(c *gin.Context) {
+	mode, ok := h.gameMode(c)
+	if !ok {
+		return
+	}
+
 	limitStr := c.DefaultQuery("limit", "10")
 	limit, err := strconv.Atoi(limitStr)
 	if err != nil || limit < 1 || limit > 100 {
@@ -248,7 +770,10 @@ func (h *Handler) GetTopGainers(c *gin.Context) {
 	}
 
 	duration := time.Duration(hours) * time.Hour
-	gainers, err := h.repository.GetTopGainers(limit, duration)
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	gainers, err := h.repository.GetTopGainers(ctx, mode, limit, duration)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to fetch top gainers",
@@ -260,12 +785,18 @@ func (h *Handler) GetTopGainers(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"data":       gainers,
 		"count":      len(gainers),
+		"mode":       mode,
 		"time_range": duration.String(),
 	})
 }
 
 // GetTopByVolume returns items with the highest trading volume
 func (h *Handler) GetTopByVolume(c *gin.Context) {
+	mode, ok := h.gameMode(c)
+	if !ok {
+		return
+	}
+
 	limitStr := c.DefaultQuery("limit", "10")
 	limit, err := strconv.Atoi(limitStr)
 	if err != nil || limit < 1 || limit > 100 {
@@ -279,7 +810,10 @@ func (h *Handler) GetTopByVolume(c *gin.Context) {
 	}
 
 	duration := time.Duration(hours) * time.Hour
-	items, err := h.repository.GetTopByVolume(limit, duration)
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	items, err := h.repository.GetTopByVolume(ctx, mode, limit, duration)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to fetch top items by volume",
@@ -291,12 +825,18 @@ func (h *Handler) GetTopByVolume(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"data":       items,
 		"count":      len(items),
+		"mode":       mode,
 		"time_range": duration.String(),
 	})
 }
 
 // GetTopLosers returns items with the highest price decreases
 func (h *Handler) GetTopLosers(c *gin.Context) {
+	mode, ok := h.gameMode(c)
+	if !ok {
+		return
+	}
+
 	limitStr := c.DefaultQuery("limit", "10")
 	limit, err := strconv.Atoi(limitStr)
 	if err != nil || limit < 1 || limit > 100 {
@@ -310,7 +850,10 @@ func (h *Handler) GetTopLosers(c *gin.Context) {
 	}
 
 	duration := time.Duration(hours) * time.Hour
-	losers, err := h.repository.GetTopLosers(limit, duration)
+	ctx, cancel := queryContext(c)
+	defer cancel()
+
+	losers, err := h.repository.GetTopLosers(ctx, mode, limit, duration)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to fetch top losers",
@@ -322,6 +865,7 @@ func (h *Handler) GetTopLosers(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"data":       losers,
 		"count":      len(losers),
+		"mode":       mode,
 		"time_range": duration.String(),
 	})
 }
\ No newline at end of file
@@ -18,6 +18,8 @@ func SetupRoutes(router *gin.Engine, handler *Handler) {
 
 		// Historical data
 		v1.GET("/history/:id", handler.GetPriceHistory)
+		v1.GET("/averages/:id", handler.GetPriceAverages)
+		v1.GET("/candles/:id", handler.GetCandles)
 		v1.GET("/change/:id", handler.GetPriceChange)
 		v1.GET("/stats/:id", handler.GetPriceStats)
 
@@ -29,4 +31,16 @@ func SetupRoutes(router *gin.Engine, handler *Handler) {
 		// Cache management
 		v1.POST("/cache/clear", handler.ClearCache)
 	}
+
+	// Admin routes (require ADMIN_TOKEN)
+	admin := v1.Group("/admin", AdminAuthMiddleware())
+	{
+		admin.GET("/cleanup/config", handler.GetCleanupConfig)
+		admin.PUT("/cleanup/config/:tier", handler.UpdateCleanupConfig)
+		admin.GET("/cleanup/logs", handler.GetCleanupLogs)
+		admin.GET("/fetch-runs", handler.GetFetchRuns)
+		admin.GET("/jobs", handler.GetJobs)
+		admin.POST("/jobs/:name/run", handler.RunJob)
+		admin.GET("/spool", handler.GetSpool)
+	}
 }
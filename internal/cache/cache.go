@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"sync"
 	"time"
 
 	"osrs-price-api/internal/models"
@@ -9,55 +10,84 @@ import (
 )
 
 const (
-	// Cache prices for 5 minutes (OSRS Wiki updates every 5 minutes)
-	defaultExpiration = 5 * time.Minute
+	// Prices are pushed by the price fetcher every 5 minutes and never expire,
+	// so the last good snapshot can always be served. Snapshots older than two
+	// fetch intervals are reported as stale.
+	StaleAfter = 10 * time.Minute
 	// Cleanup expired items every 10 minutes
 	cleanupInterval = 10 * time.Minute
 )
 
-// PriceCache handles caching of price data
+// PriceCache holds the latest price snapshot per game mode and the item catalog
+// It is filled by the background workers; API handlers only read from it
 type PriceCache struct {
 	cache *gocache.Cache
+	mu    sync.Mutex // Serializes updates of the price snapshots
+}
+
+// Snapshot is the last successfully fetched set of prices for a game mode
+type Snapshot struct {
+	Prices     map[string]models.ItemPrice
+	FetchedAt  time.Time
+	SnapshotID int64 // price_snapshots row, 0 until the snapshot has been saved
 }
 
 // NewPriceCache creates a new price cache
 func NewPriceCache() *PriceCache {
 	return &PriceCache{
-		cache: gocache.New(defaultExpiration, cleanupInterval),
+		cache: gocache.New(gocache.NoExpiration, cleanupInterval),
 	}
 }
 
-// Get retrieves a cached item price
-func (pc *PriceCache) Get(itemID string) (*models.ItemPrice, bool) {
-	if val, found := pc.cache.Get(itemID); found {
-		if price, ok := val.(models.ItemPrice); ok {
-			return &price, true
+// GetAll retrieves the cached price snapshot for a game mode
+// The returned map is shared and must not be modified
+func (pc *PriceCache) GetAll(mode string) (Snapshot, bool) {
+	if val, found := pc.cache.Get(mode + ":all_prices"); found {
+		if snapshot, ok := val.(Snapshot); ok {
+			return snapshot, true
 		}
 	}
-	return nil, false
+	return Snapshot{}, false
+}
+
+// SetAll replaces the price snapshot for a game mode
+func (pc *PriceCache) SetAll(mode string, snapshot Snapshot) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.cache.Set(mode+":all_prices", snapshot, gocache.NoExpiration)
 }
 
-// Set stores an item price in the cache
-func (pc *PriceCache) Set(itemID string, price models.ItemPrice) {
-	pc.cache.Set(itemID, price, defaultExpiration)
+// SetSnapshotID records the database snapshot the cached prices were saved
+// as; it does nothing if the cache has moved on to a newer fetch
+func (pc *PriceCache) SetSnapshotID(mode string, fetchedAt time.Time, id int64) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	snapshot, found := pc.GetAll(mode)
+	if !found || !snapshot.FetchedAt.Equal(fetchedAt) {
+		return
+	}
+	snapshot.SnapshotID = id
+	pc.cache.Set(mode+":all_prices", snapshot, gocache.NoExpiration)
 }
 
-// GetAll retrieves all cached prices
-func (pc *PriceCache) GetAll() (map[string]models.ItemPrice, bool) {
-	if val, found := pc.cache.Get("all_prices"); found {
-		if prices, ok := val.(map[string]models.ItemPrice); ok {
-			return prices, true
+// GetItemMappings retrieves the cached item catalog
+func (pc *PriceCache) GetItemMappings() (map[int]models.ItemMapping, bool) {
+	if val, found := pc.cache.Get("item_mappings"); found {
+		if mapping, ok := val.(map[int]models.ItemMapping); ok {
+			return mapping, true
 		}
 	}
 	return nil, false
 }
 
-// SetAll stores all prices in the cache
-func (pc *PriceCache) SetAll(prices map[string]models.ItemPrice) {
-	pc.cache.Set("all_prices", prices, defaultExpiration)
+// SetItemMappings stores the item catalog in the cache
+func (pc *PriceCache) SetItemMappings(mapping map[int]models.ItemMapping) {
+	pc.cache.Set("item_mappings", mapping, gocache.NoExpiration)
 }
 
 // Clear removes all items from the cache
+// Handlers fall back to the database until the workers refill it
 func (pc *PriceCache) Clear() {
 	pc.cache.Flush()
-}
\ No newline at end of file
+}
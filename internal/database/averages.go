@@ -0,0 +1,85 @@
+package database
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	"osrs-price-api/internal/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// SavePriceAverages stores one 5-minute or 1-hour window of average prices and volumes for a game mode
+// Windows that were already stored are skipped, so re-fetching a window is harmless
+func (r *Repository) SavePriceAverages(ctx context.Context, mode, timestep string, averages *models.AveragePriceResponse) (int64, error) {
+	timestamp := time.Unix(averages.Timestamp, 0).UTC()
+	if len(averages.Data) == 0 {
+		return 0, nil
+	}
+
+	rows := make([][]interface{}, 0, len(averages.Data))
+	for itemIDStr, data := range averages.Data {
+		itemID, err := strconv.Atoi(itemIDStr)
+		if err != nil {
+			log.Printf("Skipping %s price with invalid item ID %q", mode, itemIDStr)
+			continue
+		}
+
+		rows = append(rows, []interface{}{
+			mode, itemID, timestep, data.AvgHighPrice, data.AvgLowPrice, data.HighPriceVolume, data.LowPriceVolume, timestamp,
+		})
+	}
+
+	// COPY can't skip windows that exist, so stage the rows first
+	var inserted int64
+	err := r.withCopyTx(ctx, func(tx pgx.Tx) error {
+		definition := "game_mode VARCHAR(8), item_id INTEGER, timestep VARCHAR(8), avg_high BIGINT, avg_low BIGINT, high_volume BIGINT, low_volume BIGINT, timestamp TIMESTAMPTZ"
+		columns := []string{"game_mode", "item_id", "timestep", "avg_high", "avg_low", "high_volume", "low_volume", "timestamp"}
+		if err := stageRows(ctx, tx, "average_rows", definition, columns, rows); err != nil {
+			return err
+		}
+		tag, err := tx.Exec(ctx, `
+			INSERT INTO price_averages (game_mode, item_id, timestep, avg_high, avg_low, high_volume, low_volume, timestamp, created_at)
+			SELECT game_mode, item_id, timestep, avg_high, avg_low, high_volume, low_volume, timestamp, NOW()
+			FROM average_rows
+			ON CONFLICT DO NOTHING
+		`)
+		inserted = tag.RowsAffected()
+		return err
+	})
+	return inserted, err
+}
+
+// GetLatestAverageTimestamp returns the start of the most recent stored window for a timestep
+// Returns the zero time if no windows have been stored yet
+func (r *Repository) GetLatestAverageTimestamp(ctx context.Context, mode, timestep string) (time.Time, error) {
+	var latest *time.Time
+	err := r.db.WithContext(ctx).Model(&models.PriceAverage{}).
+		Select("MAX(timestamp)").
+		Where("game_mode = ? AND timestep = ?", mode, timestep).
+		Scan(&latest).Error
+	if err != nil || latest == nil {
+		return time.Time{}, err
+	}
+	return latest.UTC(), nil
+}
+
+// GetPriceAverages retrieves average price windows for an item within a time range
+func (r *Repository) GetPriceAverages(ctx context.Context, mode string, itemID int, timestep string, startTime, endTime time.Time) ([]models.PriceAverage, error) {
+	var averages []models.PriceAverage
+	err := r.db.WithContext(ctx).Where("game_mode = ? AND item_id = ? AND timestep = ? AND timestamp BETWEEN ? AND ?", mode, itemID, timestep, startTime, endTime).
+		Order("timestamp ASC").
+		Find(&averages).Error
+	return averages, err
+}
+
+// DeleteOldPriceAverages deletes average price windows older than the given date for every game mode
+func (r *Repository) DeleteOldPriceAverages(ctx context.Context, timestep string, cutoffDate time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Where("timestep = ? AND timestamp < ?", timestep, cutoffDate).Delete(&models.PriceAverage{})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	return result.RowsAffected, nil
+}
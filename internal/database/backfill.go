@@ -0,0 +1,111 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"osrs-price-api/internal/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// backfillStage is the temporary table timeseries points are copied into
+// before being inserted into a tier
+const backfillStage = "backfill_points"
+
+// backfillColumns are the columns of backfillStage
+var backfillColumns = []string{"game_mode", "item_id", "high", "low", "high_volume", "low_volume", "ts"}
+
+// backfill copies points into backfillStage and runs insert, which reads the
+// stage as v, returning the number of rows it inserted
+func (r *Repository) backfill(ctx context.Context, mode string, itemID int, points []models.TimeseriesPoint, insert string) (int64, error) {
+	if len(points) == 0 {
+		return 0, nil
+	}
+
+	rows := make([][]interface{}, 0, len(points))
+	for _, p := range points {
+		rows = append(rows, []interface{}{mode, itemID, p.AvgHighPrice, p.AvgLowPrice, p.HighPriceVolume, p.LowPriceVolume, time.Unix(p.Timestamp, 0).UTC()})
+	}
+
+	var inserted int64
+	err := r.withCopyTx(ctx, func(tx pgx.Tx) error {
+		definition := "game_mode VARCHAR(8), item_id INTEGER, high BIGINT, low BIGINT, high_volume BIGINT, low_volume BIGINT, ts TIMESTAMPTZ"
+		if err := stageRows(ctx, tx, backfillStage, definition, backfillColumns, rows); err != nil {
+			return err
+		}
+		tag, err := tx.Exec(ctx, insert)
+		inserted = tag.RowsAffected()
+		return err
+	})
+	return inserted, err
+}
+
+// BackfillPriceHistory inserts 5-minute timeseries points as raw price history
+// Points are skipped when a row for the same item already exists in that 5-minute window
+func (r *Repository) BackfillPriceHistory(ctx context.Context, mode string, itemID int, points []models.TimeseriesPoint) (int64, error) {
+	if len(points) == 0 {
+		return 0, nil
+	}
+	from, to := points[0].Timestamp, points[0].Timestamp
+	for _, p := range points {
+		from, to = min(from, p.Timestamp), max(to, p.Timestamp)
+	}
+	if _, err := r.EnsurePriceHistoryPartitions(ctx, time.Unix(from, 0), time.Unix(to, 0)); err != nil {
+		return 0, fmt.Errorf("creating partitions: %w", err)
+	}
+
+	return r.backfill(ctx, mode, itemID, points, `
+		INSERT INTO price_history (game_mode, item_id, high, low, high_volume, low_volume, timestamp, created_at)
+		SELECT v.game_mode, v.item_id, v.high, v.low, v.high_volume, v.low_volume, v.ts, NOW()
+		FROM `+backfillStage+` v
+		WHERE NOT EXISTS (
+			SELECT 1 FROM price_history p
+			WHERE p.game_mode = v.game_mode AND p.item_id = v.item_id
+			AND p.timestamp >= v.ts AND p.timestamp < v.ts + INTERVAL '5 minutes'
+		)
+	`)
+}
+
+// BackfillHourly inserts 1-hour timeseries points as hourly aggregates
+// Hours that already exist for the item are left untouched, including, with
+// TimescaleDB, the ones aggregated before the switch
+func (r *Repository) BackfillHourly(ctx context.Context, mode string, itemID int, points []models.TimeseriesPoint) (int64, error) {
+	// The Wiki only reports averages, so they stand in for the open/close/min/max values
+	return r.backfill(ctx, mode, itemID, points, `
+		INSERT INTO `+r.aggregateTable("price_history_hourly")+` (
+			game_mode, item_id, avg_high, avg_low, max_high, min_high, max_low, min_low,
+			opening_high, opening_low, closing_high, closing_low,
+			total_high_volume, total_low_volume, data_points, hour_timestamp
+		)
+		SELECT
+			v.game_mode, v.item_id, v.high, v.low, v.high, v.high, v.low, v.low,
+			v.high, v.low, v.high, v.low,
+			v.high_volume, v.low_volume, 1, v.ts
+		FROM `+backfillStage+` v
+		WHERE NOT EXISTS (
+			SELECT 1 FROM price_history_hourly h
+			WHERE h.game_mode = v.game_mode AND h.item_id = v.item_id AND h.hour_timestamp = v.ts
+		)
+		ON CONFLICT (game_mode, item_id, hour_timestamp) DO NOTHING
+	`)
+}
+
+// BackfillDaily inserts 24-hour timeseries points as daily aggregates
+// Days that already exist for the item are left untouched
+func (r *Repository) BackfillDaily(ctx context.Context, mode string, itemID int, points []models.TimeseriesPoint) (int64, error) {
+	return r.backfill(ctx, mode, itemID, points, `
+		INSERT INTO `+r.aggregateTable("price_history_daily")+` (
+			game_mode, item_id, avg_high, avg_low, max_high, min_high, max_low, min_low,
+			opening_high, opening_low, closing_high, closing_low,
+			total_high_volume, total_low_volume, volatility, data_points, day_date
+		)
+		SELECT
+			v.game_mode, v.item_id, v.high, v.low, v.high, v.high, v.low, v.low,
+			v.high, v.low, v.high, v.low,
+			v.high_volume, v.low_volume, 0, 1, (v.ts AT TIME ZONE 'UTC')::date
+		FROM `+backfillStage+` v
+		ON CONFLICT (game_mode, item_id, day_date) DO NOTHING
+	`)
+}
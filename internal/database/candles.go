@@ -0,0 +1,269 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"osrs-price-api/internal/models"
+)
+
+// candleColumns names the columns each tier's candles are computed from
+type candleColumns struct {
+	openHigh, maxHigh, minHigh, closeHigh string
+	openLow, maxLow, minLow, closeLow     string
+	highVolume, lowVolume                 string
+}
+
+// aggregateCandleColumns are shared by every aggregate tier
+var aggregateCandleColumns = candleColumns{
+	openHigh: "opening_high", maxHigh: "max_high", minHigh: "min_high", closeHigh: "closing_high",
+	openLow: "opening_low", maxLow: "max_low", minLow: "min_low", closeLow: "closing_low",
+	highVolume: "total_high_volume", lowVolume: "total_low_volume",
+}
+
+// candleSources holds the candle columns per tier, indexed like historyTiers
+// Raw rows are single prices, so the same column serves as open, high, low and close.
+var candleSources = []candleColumns{
+	{
+		openHigh: "high", maxHigh: "high", minHigh: "high", closeHigh: "high",
+		openLow: "low", maxLow: "low", minLow: "low", closeLow: "low",
+		highVolume: "high_volume", lowVolume: "low_volume",
+	},
+	aggregateCandleColumns,
+	aggregateCandleColumns,
+	aggregateCandleColumns,
+	aggregateCandleColumns,
+}
+
+// weekOffset shifts epoch-aligned weekly buckets to start on Monday (1970-01-05)
+const weekOffset = 4 * 24 * time.Hour
+
+// candleRow is one aggregated bucket as returned by the candle query
+type candleRow struct {
+	Bucket     time.Time
+	OpenHigh   int64
+	MaxHigh    int64
+	MinHigh    int64
+	CloseHigh  int64
+	HighVolume int64
+	OpenLow    int64
+	MaxLow     int64
+	MinLow     int64
+	CloseLow   int64
+	LowVolume  int64
+}
+
+// GetCandles returns OHLC candles for an item within [startTime, endTime)
+// interval is a key of models.CandleIntervals. Buckets are computed from the
+// coarsest tier whose bucket divides the interval, with the same stitching as
+// GetPriceHistory for periods that tier doesn't cover.
+func (r *Repository) GetCandles(ctx context.Context, mode string, itemID int, interval string, startTime, endTime time.Time) ([]models.Candle, error) {
+	step, ok := models.CandleIntervals[interval]
+	if !ok {
+		return nil, fmt.Errorf("unsupported interval: %s", interval)
+	}
+
+	tier := 0
+	for i, t := range historyTiers {
+		if step%t.bucket == 0 {
+			tier = i
+		}
+	}
+
+	coverage, err := r.historyCoverage(ctx, mode, itemID)
+	if err != nil {
+		return nil, err
+	}
+
+	var candles []models.Candle
+	segments := planHistory(coverage, tier, startTime, endTime)
+	for i, segment := range segments {
+		// Carry the price in effect before the range into its first buckets
+		if i == 0 {
+			seed, err := r.carriedCandle(ctx, mode, itemID, step, segment)
+			if err != nil {
+				return nil, err
+			}
+			if seed != nil {
+				candles = append(candles, *seed)
+			}
+		}
+
+		rows, err := r.loadCandleSegment(ctx, mode, itemID, step, segment)
+		if err != nil {
+			return nil, err
+		}
+		resolution := historyTiers[segment.tier].resolution
+
+		for _, row := range rows {
+			candle := models.Candle{
+				Timestamp:  row.Bucket.UTC(),
+				High:       models.OHLC{Open: row.OpenHigh, High: row.MaxHigh, Low: row.MinHigh, Close: row.CloseHigh, Volume: row.HighVolume},
+				Low:        models.OHLC{Open: row.OpenLow, High: row.MaxLow, Low: row.MinLow, Close: row.CloseLow, Volume: row.LowVolume},
+				Resolution: resolution,
+			}
+
+			// A bucket that straddles two segments arrives in two parts
+			if n := len(candles); n > 0 && candles[n-1].Timestamp.Equal(candle.Timestamp) {
+				mergeCandle(&candles[n-1], candle)
+				continue
+			}
+			candles = append(candles, candle)
+		}
+	}
+
+	end := time.Now().UTC()
+	if endTime.Before(end) {
+		end = endTime
+	}
+	candles = fillCandleGaps(candles, step, end)
+
+	// The carried candle only served to fill the start of the range
+	if len(segments) > 0 {
+		first := segments[0].from.Truncate(step)
+		for len(candles) > 0 && candles[0].Timestamp.Before(first) {
+			candles = candles[1:]
+		}
+	}
+	return candles, nil
+}
+
+// loadCandleSegment aggregates one segment of a tier into buckets of step
+// Zero prices mean the item didn't trade on that side and are ignored.
+func (r *Repository) loadCandleSegment(ctx context.Context, mode string, itemID int, step time.Duration, segment historySegment) ([]candleRow, error) {
+	tier := historyTiers[segment.tier]
+	cols := candleSources[segment.tier]
+
+	seconds := int64(step / time.Second)
+	var offset int64
+	if step == models.CandleIntervals["1w"] {
+		offset = int64(weekOffset / time.Second)
+	}
+	bucket := fmt.Sprintf("to_timestamp(floor((extract(epoch from %s) - %d) / %d) * %d + %d)",
+		tier.timestamp, offset, seconds, seconds, offset)
+
+	query := fmt.Sprintf(`
+		SELECT
+			%[1]s AS bucket,
+			COALESCE((array_agg(%[3]s ORDER BY %[2]s) FILTER (WHERE %[3]s > 0))[1], 0) AS open_high,
+			COALESCE(MAX(%[4]s) FILTER (WHERE %[4]s > 0), 0) AS max_high,
+			COALESCE(MIN(%[5]s) FILTER (WHERE %[5]s > 0), 0) AS min_high,
+			COALESCE((array_agg(%[6]s ORDER BY %[2]s DESC) FILTER (WHERE %[6]s > 0))[1], 0) AS close_high,
+			COALESCE(SUM(%[7]s), 0) AS high_volume,
+			COALESCE((array_agg(%[8]s ORDER BY %[2]s) FILTER (WHERE %[8]s > 0))[1], 0) AS open_low,
+			COALESCE(MAX(%[9]s) FILTER (WHERE %[9]s > 0), 0) AS max_low,
+			COALESCE(MIN(%[10]s) FILTER (WHERE %[10]s > 0), 0) AS min_low,
+			COALESCE((array_agg(%[11]s ORDER BY %[2]s DESC) FILTER (WHERE %[11]s > 0))[1], 0) AS close_low,
+			COALESCE(SUM(%[12]s), 0) AS low_volume
+		FROM %[13]s
+		WHERE game_mode = ? AND item_id = ? AND %[2]s >= ? AND %[2]s < ?
+		GROUP BY 1
+		ORDER BY 1
+	`, bucket, tier.column,
+		cols.openHigh, cols.maxHigh, cols.minHigh, cols.closeHigh, cols.highVolume,
+		cols.openLow, cols.maxLow, cols.minLow, cols.closeLow, cols.lowVolume,
+		tier.table)
+
+	var rows []candleRow
+	err := r.db.WithContext(ctx).Raw(query, mode, itemID, segment.from, segment.to).Scan(&rows).Error
+	return rows, err
+}
+
+// carriedCandle returns a flat candle, one bucket before the segment, at the
+// newest close of the segment's tier before it (up to models.KeyframeInterval
+// back), or nil if there is none
+func (r *Repository) carriedCandle(ctx context.Context, mode string, itemID int, step time.Duration, segment historySegment) (*models.Candle, error) {
+	tier := historyTiers[segment.tier]
+	cols := candleSources[segment.tier]
+
+	query := fmt.Sprintf(`
+		SELECT COALESCE(%[1]s, 0) AS close_high, COALESCE(%[2]s, 0) AS close_low
+		FROM %[3]s
+		WHERE game_mode = ? AND item_id = ? AND %[4]s < ? AND %[4]s >= ?
+		ORDER BY %[4]s DESC, id DESC
+		LIMIT 1
+	`, cols.closeHigh, cols.closeLow, tier.table, tier.column)
+
+	var rows []candleRow
+	err := r.db.WithContext(ctx).Raw(query, mode, itemID, segment.from, segment.from.Add(-models.KeyframeInterval)).Scan(&rows).Error
+	if err != nil || len(rows) == 0 {
+		return nil, err
+	}
+	last := models.Candle{
+		High:       models.OHLC{Close: rows[0].CloseHigh},
+		Low:        models.OHLC{Close: rows[0].CloseLow},
+		Resolution: tier.resolution,
+	}
+	seed := flatCandle(last, segment.from.Truncate(step).Add(-step))
+	return &seed, nil
+}
+
+// fillCandleGaps adds a flat candle at the previous close for every bucket
+// without stored rows, from the first candle up to end
+// Prices are only stored when an item trades, so a missing bucket means the
+// price didn't change rather than that there is no data.
+func fillCandleGaps(candles []models.Candle, step time.Duration, end time.Time) []models.Candle {
+	if len(candles) == 0 {
+		return candles
+	}
+
+	filled := make([]models.Candle, 0, len(candles))
+	for _, candle := range candles {
+		if n := len(filled); n > 0 {
+			prev := filled[n-1]
+			for t := prev.Timestamp.Add(step); t.Before(candle.Timestamp); t = t.Add(step) {
+				filled = append(filled, flatCandle(prev, t))
+			}
+		}
+		filled = append(filled, candle)
+	}
+
+	last := filled[len(filled)-1]
+	for t := last.Timestamp.Add(step); t.Before(end); t = t.Add(step) {
+		filled = append(filled, flatCandle(last, t))
+	}
+	return filled
+}
+
+// flatCandle is a bucket without trades at timestamp, priced at prev's close
+func flatCandle(prev models.Candle, timestamp time.Time) models.Candle {
+	flat := func(price int64) models.OHLC {
+		return models.OHLC{Open: price, High: price, Low: price, Close: price}
+	}
+	return models.Candle{
+		Timestamp:  timestamp,
+		High:       flat(prev.High.Close),
+		Low:        flat(prev.Low.Close),
+		Resolution: prev.Resolution,
+	}
+}
+
+// mergeCandle folds the later part of a bucket into the earlier one
+func mergeCandle(into *models.Candle, later models.Candle) {
+	mergeOHLC(&into.High, later.High)
+	mergeOHLC(&into.Low, later.Low)
+
+	// Report the coarser of the two tiers, since that limits the bucket's precision
+	if tierIndex(later.Resolution) > tierIndex(into.Resolution) {
+		into.Resolution = later.Resolution
+	}
+}
+
+// mergeOHLC combines two consecutive parts of one side of a bucket
+// Zero prices mean no trades in that part.
+func mergeOHLC(into *models.OHLC, later models.OHLC) {
+	if into.Open == 0 {
+		into.Open = later.Open
+	}
+	if later.High > into.High {
+		into.High = later.High
+	}
+	if later.Low > 0 && (into.Low == 0 || later.Low < into.Low) {
+		into.Low = later.Low
+	}
+	if later.Close > 0 {
+		into.Close = later.Close
+	}
+	into.Volume += later.Volume
+}
@@ -0,0 +1,70 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"osrs-price-api/internal/models"
+)
+
+// GetCleanupConfigs returns the retention setting of every tier
+func (r *Repository) GetCleanupConfigs(ctx context.Context) ([]models.CleanupConfig, error) {
+	var configs []models.CleanupConfig
+	err := r.db.WithContext(ctx).Order("id ASC").Find(&configs).Error
+	return configs, err
+}
+
+// UpdateCleanupConfig changes a tier's retention and returns the updated setting
+// Returns nil if the tier has no configuration row.
+func (r *Repository) UpdateCleanupConfig(ctx context.Context, tier string, retentionDays int, enabled bool) (*models.CleanupConfig, error) {
+	result := r.db.WithContext(ctx).Model(&models.CleanupConfig{}).
+		Where("tier = ?", tier).
+		Updates(map[string]interface{}{
+			"retention_days": retentionDays,
+			"enabled":        enabled,
+			"updated_at":     time.Now().UTC(),
+		})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return nil, result.Error
+	}
+
+	var config models.CleanupConfig
+	err := r.db.WithContext(ctx).Where("tier = ?", tier).First(&config).Error
+	return &config, err
+}
+
+// RecordCleanup stores the outcome of a cleanup run on the tier's configuration
+func (r *Repository) RecordCleanup(ctx context.Context, tier string, ranAt time.Time, deleted int64) error {
+	return r.db.WithContext(ctx).Model(&models.CleanupConfig{}).
+		Where("tier = ?", tier).
+		Updates(map[string]interface{}{
+			"last_cleanup":             ranAt,
+			"records_deleted_last_run": deleted,
+		}).Error
+}
+
+// SaveCleanupLog appends a run to cleanup_logs
+func (r *Repository) SaveCleanupLog(ctx context.Context, entry *models.CleanupLog) error {
+	return r.db.WithContext(ctx).Create(entry).Error
+}
+
+// GetCleanupLogs returns the most recent runs, newest first
+// job filters by models.CleanupJobCleanup or models.CleanupJobRollup; empty returns both.
+func (r *Repository) GetCleanupLogs(ctx context.Context, job string, limit int) ([]models.CleanupLog, error) {
+	query := r.db.WithContext(ctx).Order("created_at DESC").Limit(limit)
+	if job != "" {
+		query = query.Where("job = ?", job)
+	}
+	var logs []models.CleanupLog
+	err := query.Find(&logs).Error
+	return logs, err
+}
+
+// DeleteOldCleanupLogs deletes log entries older than the given date
+func (r *Repository) DeleteOldCleanupLogs(ctx context.Context, cutoffDate time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Where("created_at < ?", cutoffDate).Delete(&models.CleanupLog{})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	return result.RowsAffected, nil
+}
@@ -1,13 +1,14 @@
 package database
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
 	"time"
 
-	"osrs-price-api/internal/models"
-
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
@@ -16,6 +17,8 @@ import (
 // Config holds database configuration
 type Config struct {
 	ConnectionString string
+	MigrationsDir    string // Directory of numbered SQL migrations
+	Storage          string // StoragePostgres or StorageTimescale
 }
 
 // LoadConfig loads database configuration from environment variables
@@ -40,6 +43,8 @@ func LoadConfig() *Config {
 	
 	return &Config{
 		ConnectionString: connStr,
+		MigrationsDir:    getEnv("MIGRATIONS_DIR", "migrations"),
+		Storage:          getEnv("DB_STORAGE", StoragePostgres),
 	}
 }
 
@@ -51,10 +56,16 @@ func getEnv(key, defaultValue string) string {
 }
 
 // Connect establishes a database connection
+// Sessions are pinned to UTC, so DATE() and generate_series over timestamptz
+// bucket by UTC days whatever the server or role's TimeZone setting.
 func Connect(config *Config) (*gorm.DB, error) {
-	dsn := config.ConnectionString
+	connConfig, err := pgx.ParseConfig(config.ConnectionString)
+	if err != nil {
+		return nil, fmt.Errorf("invalid connection string: %w", err)
+	}
+	connConfig.RuntimeParams["timezone"] = "UTC"
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: stdlib.OpenDB(*connConfig)}), &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Info),
 		NowFunc: func() time.Time {
 			return time.Now().UTC()
@@ -79,57 +90,19 @@ func Connect(config *Config) (*gorm.DB, error) {
 	return db, nil
 }
 
-// AutoMigrate runs database migrations
-func AutoMigrate(db *gorm.DB) error {
+// Migrate applies every pending SQL migration from dir
+func Migrate(ctx context.Context, db *gorm.DB, dir string) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return fmt.Errorf("failed to get database instance: %w", err)
+	}
+
 	log.Println("Running database migrations...")
-	
-	if err := db.AutoMigrate(&models.PriceHistory{}); err != nil {
+	applied, err := NewMigrator(sqlDB, dir).Up(ctx)
+	if err != nil {
 		return fmt.Errorf("failed to migrate database: %w", err)
 	}
 
-	log.Println("Database migrations completed successfully")
-	return nil
-}
-
-// MigrationStatus checks the current migration status
-func MigrationStatus(db *gorm.DB) error {
-	log.Println("Checking database tables...")
-	
-	// Check if price_history table exists
-	if db.Migrator().HasTable(&models.PriceHistory{}) {
-		log.Println("✓ Table 'price_history' exists")
-		
-		// Get table info
-		var count int64
-		db.Model(&models.PriceHistory{}).Count(&count)
-		log.Printf("  - Record count: %d", count)
-		
-		// Check indexes
-		if db.Migrator().HasIndex(&models.PriceHistory{}, "idx_item_timestamp") {
-			log.Println("  - Index 'idx_item_timestamp' exists")
-		} else {
-			log.Println("  - Index 'idx_item_timestamp' missing")
-		}
-	} else {
-		log.Println("✗ Table 'price_history' does not exist")
-		log.Println("  Run migrations with: go run cmd/migrate/main.go -command=up")
-	}
-	
+	log.Printf("Database migrations completed successfully (%d applied)", applied)
 	return nil
 }
-
-// ResetDatabase drops all tables and recreates them
-func ResetDatabase(db *gorm.DB) error {
-	log.Println("Dropping all tables...")
-	
-	if err := db.Migrator().DropTable(&models.PriceHistory{}); err != nil {
-		return fmt.Errorf("failed to drop tables: %w", err)
-	}
-	
-	log.Println("Recreating tables...")
-	if err := AutoMigrate(db); err != nil {
-		return err
-	}
-	
-	return nil
-}
\ No newline at end of file
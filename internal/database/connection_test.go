@@ -0,0 +1,15 @@
+package database
+
+import "testing"
+
+func TestConnectPinsSessionsToUTC(t *testing.T) {
+	_, db := newTestRepository(t)
+
+	var zone string
+	if err := db.Raw("SHOW TimeZone").Scan(&zone).Error; err != nil {
+		t.Fatal(err)
+	}
+	if zone != "UTC" {
+		t.Errorf("session time zone = %q, want UTC", zone)
+	}
+}
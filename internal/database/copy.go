@@ -0,0 +1,58 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// Bulk writes stream rows with the COPY protocol instead of batched INSERTs,
+// which database/sql (and so GORM) doesn't expose. COPY can't skip conflicting
+// rows, so writes that must skip existing data copy into a temporary staging
+// table and insert from there.
+
+// withCopyTx runs fn in a transaction on the pgx connection underneath one of
+// the pool's connections
+func (r *Repository) withCopyTx(ctx context.Context, fn func(pgx.Tx) error) error {
+	sqlDB, err := r.db.DB()
+	if err != nil {
+		return err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn interface{}) error {
+		stdConn, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return fmt.Errorf("bulk writes need the pgx driver, got %T", driverConn)
+		}
+		return pgx.BeginFunc(ctx, stdConn.Conn(), fn)
+	})
+}
+
+// copyRows streams rows into table
+func copyRows(ctx context.Context, tx pgx.Tx, table string, columns []string, rows [][]interface{}) (int64, error) {
+	if len(rows) == 0 {
+		return 0, nil
+	}
+	n, err := tx.CopyFrom(ctx, pgx.Identifier{table}, columns, pgx.CopyFromRows(rows))
+	if err != nil {
+		return n, fmt.Errorf("copying into %s: %w", table, err)
+	}
+	return n, nil
+}
+
+// stageRows creates a temporary table named stage with the given column
+// definitions, dropped at commit, and streams rows into it
+func stageRows(ctx context.Context, tx pgx.Tx, stage, definition string, columns []string, rows [][]interface{}) error {
+	if _, err := tx.Exec(ctx, fmt.Sprintf("CREATE TEMP TABLE %s (%s) ON COMMIT DROP", stage, definition)); err != nil {
+		return fmt.Errorf("creating %s: %w", stage, err)
+	}
+	_, err := copyRows(ctx, tx, stage, columns, rows)
+	return err
+}
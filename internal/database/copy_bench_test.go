@@ -0,0 +1,142 @@
+package database
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"osrs-price-api/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Ingestion benchmarks: a 4000-item snapshot written with GORM CreateInBatches
+// (the approach COPY replaced) and with COPY, and a 90-day 5-minute backfill.
+// They need DATABASE_URL to point at a local scratch database; run them with
+//
+//	go test ./internal/database -run '^$' -bench . -benchtime 5x
+
+const (
+	benchMode   = "bench" // Game mode benchmark rows are written under, deleted afterwards
+	benchItems  = 4000    // Items per snapshot (one /latest fetch)
+	benchPoints = 25920   // 5-minute points per backfill (90 days)
+)
+
+// benchStart stamps every benchmark row, in the past so it never looks like live data
+var benchStart = time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// newBenchRepository returns a repository for the scratch database with the
+// benchmark's partition in place, deleting the benchmark's rows afterwards
+func newBenchRepository(b *testing.B) (*Repository, *gorm.DB) {
+	repo, db := newTestRepository(b)
+	ctx := context.Background()
+
+	cleanup := func() {
+		for _, table := range []string{"fetch_runs", "price_history", "price_snapshots"} {
+			if err := db.Exec("DELETE FROM "+table+" WHERE game_mode = ?", benchMode).Error; err != nil {
+				b.Errorf("deleting benchmark rows from %s: %v", table, err)
+			}
+		}
+	}
+	cleanup()
+	b.Cleanup(cleanup)
+
+	// The GORM benchmark inserts without going through the repository
+	if _, err := repo.EnsurePriceHistoryPartitions(ctx, benchStart, benchStart); err != nil {
+		b.Fatal(err)
+	}
+	return repo, db
+}
+
+// benchPrices returns a snapshot of benchItems prices
+func benchPrices() map[string]models.ItemPrice {
+	prices := make(map[string]models.ItemPrice, benchItems)
+	for id := 1; id <= benchItems; id++ {
+		prices[strconv.Itoa(id)] = models.ItemPrice{
+			High: int64(1000 + id), HighTime: benchStart.Unix(),
+			Low: int64(900 + id), LowTime: benchStart.Unix(),
+		}
+	}
+	return prices
+}
+
+// reportRows reports throughput as rows written per second
+func reportRows(b *testing.B, rowsPerOp int) {
+	b.ReportMetric(float64(rowsPerOp*b.N)/b.Elapsed().Seconds(), "rows/s")
+}
+
+func BenchmarkSnapshotGORMInsert(b *testing.B) {
+	_, db := newBenchRepository(b)
+	ctx := context.Background()
+
+	records := make([]models.PriceHistory, 0, benchItems)
+	for id, price := range benchPrices() {
+		itemID, _ := strconv.Atoi(id)
+		records = append(records, models.PriceHistory{
+			GameMode: benchMode, ItemID: itemID, High: price.High, HighTime: price.HighTime,
+			Low: price.Low, LowTime: price.LowTime, Timestamp: benchStart,
+		})
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := range records {
+			records[j].ID = 0
+		}
+		if err := db.WithContext(ctx).CreateInBatches(records, 100).Error; err != nil {
+			b.Fatal(err)
+		}
+	}
+	reportRows(b, benchItems)
+}
+
+func BenchmarkSavePriceSnapshot(b *testing.B) {
+	repo, _ := newBenchRepository(b)
+	ctx := context.Background()
+	prices := benchPrices()
+	hash := models.HashPrices(prices)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		snapshot := &models.PriceSnapshot{
+			GameMode: benchMode, SnapshotTime: benchStart, FetchedAt: benchStart,
+			ContentHash: hash, ItemsTotal: benchItems, ItemsChanged: benchItems,
+		}
+		run := &models.FetchRun{
+			GameMode: benchMode, Source: "bench", StartedAt: benchStart, FinishedAt: benchStart,
+			Items: benchItems, Status: models.FetchStatusSuccess,
+		}
+		if err := repo.SavePriceSnapshot(ctx, snapshot, prices, run); err != nil {
+			b.Fatal(err)
+		}
+	}
+	reportRows(b, benchItems)
+}
+
+func BenchmarkCopyBackfillPriceHistory(b *testing.B) {
+	repo, _ := newBenchRepository(b)
+	ctx := context.Background()
+
+	series := make([]models.TimeseriesPoint, benchPoints)
+	for i := range series {
+		series[i] = models.TimeseriesPoint{
+			Timestamp:    benchStart.Add(time.Duration(i) * 5 * time.Minute).Unix(),
+			AvgHighPrice: int64(1000 + i%50),
+			AvgLowPrice:  int64(950 + i%50),
+		}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// Every run backfills a new item, so no point is skipped as already stored
+		inserted, err := repo.BackfillPriceHistory(ctx, benchMode, 1_000_000+i, series)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if inserted != benchPoints {
+			b.Fatalf("inserted %d of %d points", inserted, benchPoints)
+		}
+	}
+	reportRows(b, benchPoints)
+}
@@ -0,0 +1,49 @@
+package database
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestRepository connects to the database in DATABASE_URL, migrates it and
+// sets up the configured storage, converting it for TimescaleDB if need be, or
+// skips the test if it isn't set
+// Tests write rows far in the past and delete them again, but DATABASE_URL
+// should still point at a local scratch database.
+func newTestRepository(tb testing.TB) (*Repository, *gorm.DB) {
+	tb.Helper()
+	if os.Getenv("DATABASE_URL") == "" {
+		tb.Skip("DATABASE_URL not set")
+	}
+
+	config := LoadConfig()
+	db, err := Connect(config)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	// Statement logging would drown the test output and the benchmark timings
+	db = db.Session(&gorm.Session{Logger: logger.Discard})
+	sqlDB, err := db.DB()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { sqlDB.Close() })
+
+	ctx := context.Background()
+	if err := Migrate(ctx, db, "../../migrations"); err != nil {
+		tb.Fatal(err)
+	}
+	if config.Storage == StorageTimescale {
+		if err := ConvertToTimescale(ctx, db); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	if err := SetupStorage(ctx, db, config.Storage); err != nil {
+		tb.Fatal(err)
+	}
+	return NewRepository(db, config.Storage), db
+}
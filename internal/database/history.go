@@ -0,0 +1,273 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"osrs-price-api/internal/models"
+)
+
+// historyTier is one storage tier and where its rows live
+type historyTier struct {
+	resolution string
+	bucket     time.Duration // Nominal size, for comparing tiers (months vary)
+	table      string
+	column     string // Bucket column, for filtering and ordering
+	timestamp  string // Bucket column as a timestamp
+	step       string // Bucket size as a Postgres interval
+}
+
+// historyTiers lists the storage tiers from finest to coarsest
+var historyTiers = []historyTier{
+	{models.ResolutionRaw, 5 * time.Minute, "price_history", "timestamp", "timestamp", "5 minutes"},
+	{models.ResolutionHourly, time.Hour, "price_history_hourly", "hour_timestamp", "hour_timestamp", "1 hour"},
+	{models.ResolutionDaily, 24 * time.Hour, "price_history_daily", "day_date", "day_date::timestamp", "1 day"},
+	{models.ResolutionWeekly, 7 * 24 * time.Hour, "price_history_weekly", "week_start", "week_start::timestamp", "1 week"},
+	{models.ResolutionMonthly, 30 * 24 * time.Hour, "price_history_monthly", "month_start", "month_start::timestamp", "1 month"},
+}
+
+// farFuture stands in for "no upper bound" on a tier's coverage
+var farFuture = time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// tierCoverage is the span a tier holds data for: [oldest, end)
+type tierCoverage struct {
+	oldest time.Time
+	end    time.Time
+}
+
+func (c tierCoverage) covers(t time.Time) bool {
+	return !t.Before(c.oldest) && t.Before(c.end)
+}
+
+// historySegment is a half-open time range served by one tier
+type historySegment struct {
+	tier     int
+	from, to time.Time
+}
+
+// GetPriceHistory retrieves price history for an item within [startTime, endTime)
+// as one continuous series. resolution is the preferred tier (see
+// models.ResolutionForSpan for a default); parts of the range it doesn't cover
+// are stitched in from other tiers:
+// - older than its oldest row: the next coarser tier that has the data
+// - newer than its rollup watermark: the next finer tier that has the data
+// Segments never overlap and each point reports the resolution it came from.
+func (r *Repository) GetPriceHistory(ctx context.Context, mode string, itemID int, resolution string, startTime, endTime time.Time) ([]models.PriceHistory, error) {
+	requested := tierIndex(resolution)
+	if requested < 0 {
+		return nil, fmt.Errorf("unsupported resolution: %s", resolution)
+	}
+
+	coverage, err := r.historyCoverage(ctx, mode, itemID)
+	if err != nil {
+		return nil, err
+	}
+
+	var history []models.PriceHistory
+	for _, segment := range planHistory(coverage, requested, startTime, endTime) {
+		points, err := r.loadHistorySegment(ctx, mode, itemID, segment)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, points...)
+	}
+	return history, nil
+}
+
+// tierIndex returns the position of a resolution in historyTiers, or -1
+func tierIndex(resolution string) int {
+	for i, tier := range historyTiers {
+		if tier.resolution == resolution {
+			return i
+		}
+	}
+	return -1
+}
+
+// historyCoverage returns what each tier holds for an item: from its oldest row
+// up to the tier's rollup watermark (raw data and tiers never rolled up are open-ended)
+func (r *Repository) historyCoverage(ctx context.Context, mode string, itemID int) ([]tierCoverage, error) {
+	// Oldest row of every tier in one round trip
+	selects := make([]string, len(historyTiers))
+	var args []interface{}
+	oldest := make([]sql.NullTime, len(historyTiers))
+	dest := make([]interface{}, len(historyTiers))
+	for i, tier := range historyTiers {
+		selects[i] = fmt.Sprintf("(SELECT MIN(%s) FROM %s WHERE game_mode = ? AND item_id = ?)", tier.timestamp, tier.table)
+		args = append(args, mode, itemID)
+		dest[i] = &oldest[i]
+	}
+	err := r.db.WithContext(ctx).Raw("SELECT "+strings.Join(selects, ", "), args...).Row().Scan(dest...)
+	if err != nil {
+		return nil, err
+	}
+
+	var watermarks []models.RollupWatermark
+	if err := r.db.WithContext(ctx).Find(&watermarks).Error; err != nil {
+		return nil, err
+	}
+	ends := make(map[string]time.Time, len(watermarks))
+	for _, w := range watermarks {
+		ends[w.Tier] = w.Watermark.UTC()
+	}
+
+	coverage := make([]tierCoverage, len(historyTiers))
+	for i, first := range oldest {
+		if !first.Valid {
+			continue // No rows: the zero range covers nothing
+		}
+		coverage[i] = tierCoverage{oldest: first.Time.UTC(), end: farFuture}
+		if end, ok := ends[historyTiers[i].resolution]; ok && !end.IsZero() {
+			coverage[i].end = end
+		}
+	}
+	return coverage, nil
+}
+
+// planHistory splits [from, to) into non-overlapping segments, choosing at
+// each point the requested tier if it has the data, otherwise the nearest
+// tier toward coarser data for older points and toward finer data for newer ones
+func planHistory(coverage []tierCoverage, requested int, from, to time.Time) []historySegment {
+	// Include the bucket that contains the requested start
+	from = models.BucketStart(historyTiers[requested].resolution, from)
+
+	// The chosen tier can only change where some tier's coverage starts or ends
+	boundaries := []time.Time{from}
+	for _, c := range coverage {
+		for _, b := range []time.Time{c.oldest, c.end} {
+			if b.After(from) && b.Before(to) {
+				boundaries = append(boundaries, b)
+			}
+		}
+	}
+	sort.Slice(boundaries, func(i, j int) bool {
+		return boundaries[i].Before(boundaries[j])
+	})
+	boundaries = append(boundaries, to)
+
+	var segments []historySegment
+	for i := 0; i+1 < len(boundaries); i++ {
+		start, end := boundaries[i], boundaries[i+1]
+		if !start.Before(end) {
+			continue
+		}
+		tier := chooseTier(coverage, requested, start)
+		if tier < 0 {
+			continue
+		}
+		if n := len(segments); n > 0 && segments[n-1].tier == tier && segments[n-1].to.Equal(start) {
+			segments[n-1].to = end
+			continue
+		}
+		segments = append(segments, historySegment{tier: tier, from: start, to: end})
+	}
+
+	// Move each switch between tiers to a bucket boundary of the coarser tier,
+	// so a coarse bucket never overlaps finer points
+	for i := 1; i < len(segments); i++ {
+		prev, cur := &segments[i-1], &segments[i]
+		if !prev.to.Equal(cur.from) {
+			continue
+		}
+		coarser := historyTiers[prev.tier].resolution
+		if cur.tier > prev.tier {
+			coarser = historyTiers[cur.tier].resolution
+		}
+		boundary := models.BucketStart(coarser, cur.from)
+		if boundary.Before(cur.from) {
+			boundary = models.BucketEnd(coarser, cur.from)
+		}
+		if boundary.After(cur.to) {
+			boundary = cur.to
+		}
+		prev.to, cur.from = boundary, boundary
+	}
+	return segments
+}
+
+// chooseTier picks the tier that serves the point t, or -1 if none has data
+func chooseTier(coverage []tierCoverage, requested int, t time.Time) int {
+	if coverage[requested].covers(t) {
+		return requested
+	}
+
+	// Older than the requested tier's data: prefer coarser tiers, which keep
+	// history longer. Newer than its watermark: prefer finer tiers, which
+	// haven't been rolled up yet. Either way the nearest tier comes first.
+	var coarser, finer []int
+	for i := requested + 1; i < len(coverage); i++ {
+		coarser = append(coarser, i)
+	}
+	for i := requested - 1; i >= 0; i-- {
+		finer = append(finer, i)
+	}
+	order := append(finer, coarser...)
+	if t.Before(coverage[requested].oldest) {
+		order = append(coarser, finer...)
+	}
+
+	for _, i := range order {
+		if coverage[i].covers(t) {
+			return i
+		}
+	}
+	return -1
+}
+
+// loadHistorySegment reads one segment from its tier as PriceHistory points
+// Aggregate buckets are reported by their average prices and total volumes.
+func (r *Repository) loadHistorySegment(ctx context.Context, mode string, itemID int, segment historySegment) ([]models.PriceHistory, error) {
+	tier := historyTiers[segment.tier]
+	query, args := seriesQuery(tier, mode, itemID, segment.from, segment.to)
+
+	var history []models.PriceHistory
+	err := r.db.WithContext(ctx).Raw(query, args...).Scan(&history).Error
+
+	for i := range history {
+		history[i].Resolution = tier.resolution
+	}
+	return history, err
+}
+
+// seriesQuery builds a query for an item's points on a tier's bucket grid
+// within [from, to), never past the current time
+// Raw prices are only stored when an item changes (and at least once per
+// models.KeyframeInterval), so every grid point carries forward the newest row
+// at or before its bucket end; volumes only count in the row's own bucket.
+func seriesQuery(tier historyTier, mode string, itemID int, from, to time.Time) (string, []interface{}) {
+	high, low, highVolume, lowVolume := "high", "low", "high_volume", "low_volume"
+	if tier.resolution != models.ResolutionRaw {
+		high, low, highVolume, lowVolume = "avg_high", "avg_low", "total_high_volume", "total_low_volume"
+	}
+
+	// The grid starts at the first bucket boundary at or after from
+	start := models.BucketStart(tier.resolution, from)
+	if start.Before(from) {
+		start = models.BucketEnd(tier.resolution, from)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT ?::varchar AS game_mode, ?::integer AS item_id, p.high, p.low,
+			CASE WHEN p.ts >= g.t THEN p.high_volume ELSE 0 END AS high_volume,
+			CASE WHEN p.ts >= g.t THEN p.low_volume ELSE 0 END AS low_volume,
+			g.t AS timestamp
+		FROM generate_series(?::timestamptz, LEAST(?::timestamptz, NOW()), INTERVAL '%[1]s') AS g(t)
+		CROSS JOIN LATERAL (
+			SELECT %[2]s AS high, %[3]s AS low, %[4]s AS high_volume, %[5]s AS low_volume, %[6]s AS ts
+			FROM %[7]s
+			WHERE game_mode = ? AND item_id = ?
+				AND %[8]s < g.t + INTERVAL '%[1]s' AND %[8]s >= g.t - ? * INTERVAL '1 second'
+			ORDER BY %[8]s DESC, id DESC
+			LIMIT 1
+		) AS p
+		WHERE g.t < ?
+		ORDER BY g.t
+	`, tier.step, high, low, highVolume, lowVolume, tier.timestamp, tier.table, tier.column)
+
+	args := []interface{}{mode, itemID, start, to, mode, itemID, keyframeSeconds, to}
+	return query, args
+}
@@ -0,0 +1,61 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"osrs-price-api/internal/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// SaveItemMappings upserts the item catalog, replacing changed names and values
+func (r *Repository) SaveItemMappings(ctx context.Context, items []models.ItemMapping) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	rows := make([][]interface{}, 0, len(items))
+	for i := range items {
+		items[i].UpdatedAt = now
+		item := items[i]
+		rows = append(rows, []interface{}{
+			item.ID, item.Name, item.Examine, item.Members, item.BuyLimit,
+			item.LowAlch, item.HighAlch, item.Value, item.Icon, item.UpdatedAt,
+		})
+	}
+
+	return r.withCopyTx(ctx, func(tx pgx.Tx) error {
+		definition := "id INTEGER, name TEXT, examine TEXT, members BOOLEAN, buy_limit INTEGER, low_alch BIGINT, high_alch BIGINT, value BIGINT, icon TEXT, updated_at TIMESTAMPTZ"
+		columns := []string{"id", "name", "examine", "members", "buy_limit", "low_alch", "high_alch", "value", "icon", "updated_at"}
+		if err := stageRows(ctx, tx, "item_rows", definition, columns, rows); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, `
+			INSERT INTO items (id, name, examine, members, buy_limit, low_alch, high_alch, value, icon, updated_at)
+			SELECT DISTINCT ON (id) id, name, examine, members, buy_limit, low_alch, high_alch, value, icon, updated_at
+			FROM item_rows
+			ORDER BY id
+			ON CONFLICT (id) DO UPDATE SET
+				name = EXCLUDED.name, examine = EXCLUDED.examine, members = EXCLUDED.members,
+				buy_limit = EXCLUDED.buy_limit, low_alch = EXCLUDED.low_alch, high_alch = EXCLUDED.high_alch,
+				value = EXCLUDED.value, icon = EXCLUDED.icon, updated_at = EXCLUDED.updated_at
+		`)
+		return err
+	})
+}
+
+// GetItemMappings returns the full item catalog keyed by item ID
+func (r *Repository) GetItemMappings(ctx context.Context) (map[int]models.ItemMapping, error) {
+	var items []models.ItemMapping
+	if err := r.db.WithContext(ctx).Find(&items).Error; err != nil {
+		return nil, err
+	}
+
+	mapping := make(map[int]models.ItemMapping, len(items))
+	for _, item := range items {
+		mapping[item.ID] = item
+	}
+	return mapping, nil
+}
@@ -0,0 +1,422 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrationLockID is the Postgres advisory lock key held while migrating, so
+// several instances starting at once don't apply the same migration twice
+// (the leader lock is 7_245_302 and the TimescaleDB conversion's 7_245_303)
+const migrationLockID = 7_245_301
+
+// migrationFile matches NNN_name.sql and NNN_name.down.sql
+var migrationFile = regexp.MustCompile(`^(\d+)_([a-z0-9_]+?)(\.down)?\.sql$`)
+
+// Migration is one numbered schema change from the migrations directory
+type Migration struct {
+	Version  int
+	Name     string
+	UpFile   string
+	DownFile string // Empty if the migration has no rollback
+	Checksum string // SHA-256 of the up file
+}
+
+// MigrationStatus describes one migration in the directory or the database
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+	Modified  bool // The up file changed after it was applied
+	Missing   bool // Applied, but the file no longer exists
+}
+
+// appliedMigration is a row of the schema_migrations table
+type appliedMigration struct {
+	name      string
+	checksum  string
+	appliedAt time.Time
+}
+
+// Migrator applies the SQL files in the migrations directory and records
+// them in the schema_migrations table
+// Each migration runs in its own transaction together with its bookkeeping row,
+// so a failing file leaves the schema exactly as it was before that file.
+type Migrator struct {
+	db  *sql.DB
+	dir string
+}
+
+// NewMigrator creates a migrator for the given database and migrations directory
+func NewMigrator(db *sql.DB, dir string) *Migrator {
+	return &Migrator{db: db, dir: dir}
+}
+
+// Load reads the migrations directory, ordered by version
+func (m *Migrator) Load() ([]Migration, error) {
+	entries, err := os.ReadDir(m.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations directory: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := migrationFile.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		version, _ := strconv.Atoi(match[1])
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %03d has two names: %s and %s", version, migration.Name, match[2])
+		}
+
+		path := filepath.Join(m.dir, entry.Name())
+		if match[3] != "" {
+			migration.DownFile = path
+			continue
+		}
+
+		body, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		sum := sha256.Sum256(body)
+		migration.UpFile = path
+		migration.Checksum = hex.EncodeToString(sum[:])
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.UpFile == "" {
+			return nil, fmt.Errorf("migration %03d_%s has a down file but no up file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Up applies every pending migration and returns how many were applied
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	return m.Goto(ctx, -1)
+}
+
+// Down rolls back the last steps applied migrations and returns how many were rolled back
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	if steps < 1 {
+		return 0, fmt.Errorf("steps must be at least 1")
+	}
+
+	count := 0
+	err := m.withLock(ctx, func(conn *sql.Conn, migrations []Migration, applied map[int]appliedMigration) error {
+		versions := appliedVersions(applied)
+		for i := len(versions) - 1; i >= 0 && count < steps; i-- {
+			if err := m.rollback(ctx, conn, migrations, versions[i]); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Goto migrates up or down until version is the newest applied migration
+// A negative version means the newest migration in the directory; 0 rolls back everything.
+func (m *Migrator) Goto(ctx context.Context, version int) (int, error) {
+	count := 0
+	err := m.withLock(ctx, func(conn *sql.Conn, migrations []Migration, applied map[int]appliedMigration) error {
+		if version > 0 && findMigration(migrations, version) == nil {
+			return fmt.Errorf("no migration with version %03d", version)
+		}
+
+		// Roll back anything newer than the target, newest first
+		versions := appliedVersions(applied)
+		for i := len(versions) - 1; i >= 0; i-- {
+			if version < 0 || versions[i] <= version {
+				break
+			}
+			if err := m.rollback(ctx, conn, migrations, versions[i]); err != nil {
+				return err
+			}
+			count++
+		}
+
+		// Apply anything pending up to the target, oldest first
+		for _, migration := range migrations {
+			if version >= 0 && migration.Version > version {
+				break
+			}
+			if _, done := applied[migration.Version]; done {
+				continue
+			}
+			if err := m.apply(ctx, conn, migration); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Status lists every migration in the directory and every applied migration
+// whose file has since been removed
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	migrations, err := m.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.ensureTable(ctx, m.db); err != nil {
+		return nil, err
+	}
+	applied, err := m.applied(ctx, m.db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, migration := range migrations {
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if row, ok := applied[migration.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = row.appliedAt
+			status.Modified = row.checksum != migration.Checksum
+		}
+		statuses = append(statuses, status)
+	}
+	for version, row := range applied {
+		if findMigration(migrations, version) == nil {
+			statuses = append(statuses, MigrationStatus{
+				Version:   version,
+				Name:      row.name,
+				Applied:   true,
+				AppliedAt: row.appliedAt,
+				Missing:   true,
+			})
+		}
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+	return statuses, nil
+}
+
+// Create scaffolds an empty up/down pair numbered after the newest migration
+// and returns the paths of the new files
+func (m *Migrator) Create(name string) (string, string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = strings.NewReplacer(" ", "_", "-", "_").Replace(name)
+	if !regexp.MustCompile(`^[a-z0-9_]+$`).MatchString(name) {
+		return "", "", fmt.Errorf("invalid migration name %q: use letters, digits and underscores", name)
+	}
+
+	migrations, err := m.Load()
+	if err != nil {
+		return "", "", err
+	}
+	version := 1
+	if len(migrations) > 0 {
+		version = migrations[len(migrations)-1].Version + 1
+	}
+
+	base := filepath.Join(m.dir, fmt.Sprintf("%03d_%s", version, name))
+	upFile, downFile := base+".sql", base+".down.sql"
+	title := strings.ReplaceAll(name, "_", " ")
+
+	up := fmt.Sprintf("-- Migration: %s\n-- Created: %s\n\n", title, time.Now().UTC().Format("2006-01-02"))
+	if err := os.WriteFile(upFile, []byte(up), 0o644); err != nil {
+		return "", "", fmt.Errorf("failed to create %s: %w", upFile, err)
+	}
+	down := fmt.Sprintf("-- Rollback: %s\n\n", title)
+	if err := os.WriteFile(downFile, []byte(down), 0o644); err != nil {
+		return "", "", fmt.Errorf("failed to create %s: %w", downFile, err)
+	}
+	return upFile, downFile, nil
+}
+
+// withLock runs fn on a single connection holding the migration advisory lock,
+// after checking that no applied migration has been edited since
+func (m *Migrator) withLock(ctx context.Context, fn func(*sql.Conn, []Migration, map[int]appliedMigration) error) error {
+	migrations, err := m.Load()
+	if err != nil {
+		return err
+	}
+
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get database connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockID); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockID)
+
+	if err := m.ensureTable(ctx, conn); err != nil {
+		return err
+	}
+	applied, err := m.applied(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	// An applied file that changed no longer describes the schema; refuse to
+	// build on top of it until it is reverted or given a new migration
+	var modified []string
+	for _, migration := range migrations {
+		if row, ok := applied[migration.Version]; ok && row.checksum != migration.Checksum {
+			modified = append(modified, filepath.Base(migration.UpFile))
+		}
+	}
+	if len(modified) > 0 {
+		return fmt.Errorf("applied migrations were modified: %s", strings.Join(modified, ", "))
+	}
+
+	return fn(conn, migrations, applied)
+}
+
+// execer is satisfied by *sql.DB, *sql.Conn and *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// ensureTable creates the schema_migrations bookkeeping table
+func (m *Migrator) ensureTable(ctx context.Context, db execer) error {
+	_, err := db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			checksum VARCHAR(64) NOT NULL,
+			applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+	return nil
+}
+
+// applied returns the rows of schema_migrations keyed by version
+func (m *Migrator) applied(ctx context.Context, db execer) (map[int]appliedMigration, error) {
+	rows, err := db.QueryContext(ctx, "SELECT version, name, checksum, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]appliedMigration)
+	for rows.Next() {
+		var version int
+		var row appliedMigration
+		if err := rows.Scan(&version, &row.name, &row.checksum, &row.appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+		}
+		applied[version] = row
+	}
+	return applied, rows.Err()
+}
+
+// apply runs one up file and records it in the same transaction
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, migration Migration) error {
+	body, err := os.ReadFile(migration.UpFile)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", migration.UpFile, err)
+	}
+
+	log.Printf("Applying migration %03d_%s", migration.Version, migration.Name)
+	return inTransaction(ctx, conn, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, string(body)); err != nil {
+			return fmt.Errorf("migration %03d_%s failed: %w", migration.Version, migration.Name, err)
+		}
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)",
+			migration.Version, migration.Name, migration.Checksum)
+		return err
+	})
+}
+
+// rollback runs one down file and removes its record in the same transaction
+func (m *Migrator) rollback(ctx context.Context, conn *sql.Conn, migrations []Migration, version int) error {
+	migration := findMigration(migrations, version)
+	if migration == nil {
+		return fmt.Errorf("cannot roll back migration %03d: its files no longer exist", version)
+	}
+	if migration.DownFile == "" {
+		return fmt.Errorf("cannot roll back migration %03d_%s: it has no down file", migration.Version, migration.Name)
+	}
+
+	body, err := os.ReadFile(migration.DownFile)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", migration.DownFile, err)
+	}
+
+	log.Printf("Rolling back migration %03d_%s", migration.Version, migration.Name)
+	return inTransaction(ctx, conn, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, string(body)); err != nil {
+			return fmt.Errorf("rollback of %03d_%s failed: %w", migration.Version, migration.Name, err)
+		}
+		_, err := tx.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = $1", migration.Version)
+		return err
+	})
+}
+
+// inTransaction runs fn in a transaction on conn, committing only if it succeeds
+func inTransaction(ctx context.Context, conn *sql.Conn, fn func(*sql.Tx) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	if err := fn(tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+			log.Printf("Error rolling back migration transaction: %v", rbErr)
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+// appliedVersions returns the applied versions in ascending order
+func appliedVersions(applied map[int]appliedMigration) []int {
+	versions := make([]int, 0, len(applied))
+	for version := range applied {
+		versions = append(versions, version)
+	}
+	sort.Ints(versions)
+	return versions
+}
+
+// findMigration returns the migration with the given version, or nil
+func findMigration(migrations []Migration, version int) *Migration {
+	for i := range migrations {
+		if migrations[i].Version == version {
+			return &migrations[i]
+		}
+	}
+	return nil
+}
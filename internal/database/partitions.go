@@ -0,0 +1,104 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// price_history is range-partitioned by day (UTC); rows older than the
+// migration to partitioning live in a single price_history_legacy partition.
+// Partitions are created ahead of time by the cleanup job, and on demand when
+// rows are written outside of them, and dropped once all of their rows have
+// expired. With TimescaleDB it is a hypertable whose chunks TimescaleDB
+// creates itself.
+
+// pricePartition is one partition of price_history
+type pricePartition struct {
+	Name  string
+	Upper time.Time // Exclusive upper bound of its range
+}
+
+// EnsurePriceHistoryPartitions creates the daily price_history partitions
+// covering from to to that don't exist yet, returning how many it created
+func (r *Repository) EnsurePriceHistoryPartitions(ctx context.Context, from, to time.Time) (int, error) {
+	if r.timescale {
+		return 0, nil
+	}
+	var created int
+	err := r.db.WithContext(ctx).Raw("SELECT ensure_price_history_partitions(?, ?)", from, to).Scan(&created).Error
+	return created, err
+}
+
+// expiredPricePartitions returns the price_history partitions whose rows are
+// all older than cutoff, oldest first
+func (r *Repository) expiredPricePartitions(ctx context.Context, cutoff time.Time) ([]pricePartition, error) {
+	// Bounds are rendered in the session time zone and parsed back in it
+	var partitions []pricePartition
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT name, upper
+		FROM (
+			SELECT c.relname AS name,
+				substring(pg_get_expr(c.relpartbound, c.oid) FROM 'TO \(''([^'']+)''\)')::timestamptz AS upper
+			FROM pg_inherits i
+			JOIN pg_class c ON c.oid = i.inhrelid
+			WHERE i.inhparent = 'price_history'::regclass
+		) p
+		WHERE upper <= ?
+		ORDER BY upper
+	`, cutoff).Scan(&partitions).Error
+	return partitions, err
+}
+
+// DeleteOldPriceHistory drops the price_history partitions (or with
+// TimescaleDB, chunks) holding only rows older than the given date, along with
+// the snapshots those rows were stored from, and returns how many rows were
+// dropped
+// The partition the cutoff falls in is kept whole until it has expired, so raw
+// data outlives its retention by up to a day.
+func (r *Repository) DeleteOldPriceHistory(ctx context.Context, cutoffDate time.Time) (int64, error) {
+	dropPartitions := r.dropPricePartitions
+	if r.timescale {
+		dropPartitions = func(ctx context.Context, cutoff time.Time) (int64, time.Time, error) {
+			return r.dropChunks(ctx, "price_history", "timestamp", "timestamptz", cutoff)
+		}
+	}
+
+	dropped, droppedTo, err := dropPartitions(ctx, cutoffDate)
+	if err != nil || droppedTo.IsZero() {
+		return dropped, err
+	}
+	if err := r.db.WithContext(ctx).Exec("DELETE FROM price_snapshots WHERE snapshot_time < ?", droppedTo).Error; err != nil {
+		return dropped, err
+	}
+	return dropped, nil
+}
+
+// dropPricePartitions drops the expired price_history partitions, returning
+// how many rows they held and where the newest of them ended (zero if none
+// was dropped)
+func (r *Repository) dropPricePartitions(ctx context.Context, cutoff time.Time) (int64, time.Time, error) {
+	partitions, err := r.expiredPricePartitions(ctx, cutoff)
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("listing partitions: %w", err)
+	}
+
+	var dropped int64
+	var droppedTo time.Time
+	for _, partition := range partitions {
+		table := pgx.Identifier{partition.Name}.Sanitize()
+
+		var rows int64
+		if err := r.db.WithContext(ctx).Raw("SELECT COUNT(*) FROM " + table).Scan(&rows).Error; err != nil {
+			return dropped, droppedTo, fmt.Errorf("counting rows in %s: %w", partition.Name, err)
+		}
+		if err := r.db.WithContext(ctx).Exec("DROP TABLE " + table).Error; err != nil {
+			return dropped, droppedTo, fmt.Errorf("dropping %s: %w", partition.Name, err)
+		}
+		dropped += rows
+		droppedTo = partition.Upper
+	}
+	return dropped, droppedTo, nil
+}
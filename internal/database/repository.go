@@ -1,6 +1,8 @@
 package database
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -11,47 +13,20 @@ import (
 
 // Repository handles database operations
 type Repository struct {
-	db *gorm.DB
+	db        *gorm.DB
+	timescale bool // Storage is StorageTimescale
 }
 
-// NewRepository creates a new repository instance
-func NewRepository(db *gorm.DB) *Repository {
-	return &Repository{db: db}
-}
-
-// SavePriceHistory saves a batch of price history records
-func (r *Repository) SavePriceHistory(prices map[string]models.ItemPrice) error {
-	timestamp := time.Now().UTC()
-	
-	var records []models.PriceHistory
-	for itemIDStr, price := range prices {
-		var itemID int
-		fmt.Sscanf(itemIDStr, "%d", &itemID)
-		
-		records = append(records, models.PriceHistory{
-			ItemID:     itemID,
-			High:       price.High,
-			HighTime:   price.HighTime,
-			Low:        price.Low,
-			LowTime:    price.LowTime,
-			HighVolume: price.HighVolume,
-			LowVolume:  price.LowVolume,
-			Timestamp:  timestamp,
-		})
-	}
-
-	// Batch insert for better performance
-	if len(records) > 0 {
-		return r.db.CreateInBatches(records, 100).Error
-	}
-
-	return nil
+// NewRepository creates a new repository instance for a storage backend
+// (StoragePostgres or StorageTimescale); SetupStorage must have prepared it
+func NewRepository(db *gorm.DB, storage string) *Repository {
+	return &Repository{db: db, timescale: storage == StorageTimescale}
 }
 
 // GetLatestPrice retrieves the most recent price for an item
-func (r *Repository) GetLatestPrice(itemID int) (*models.PriceHistory, error) {
+func (r *Repository) GetLatestPrice(ctx context.Context, mode string, itemID int) (*models.PriceHistory, error) {
 	var price models.PriceHistory
-	err := r.db.Where("item_id = ?", itemID).
+	err := r.db.WithContext(ctx).Where("game_mode = ? AND item_id = ?", mode, itemID).
 		Order("timestamp DESC").
 		First(&price).Error
 	
@@ -61,85 +36,72 @@ func (r *Repository) GetLatestPrice(itemID int) (*models.PriceHistory, error) {
 	return &price, nil
 }
 
-// GetPriceHistory retrieves price history for an item within a time range
-// Automatically uses the appropriate table based on time range:
-// - Raw data (5-min) for last 7 days
-// - Hourly aggregates for 8-90 days
-// - Daily aggregates for 90+ days
-func (r *Repository) GetPriceHistory(itemID int, startTime, endTime time.Time) ([]models.PriceHistory, error) {
-	now := time.Now().UTC()
-	daysAgo := int(now.Sub(startTime).Hours() / 24)
-	
-	// If requesting recent data (within 7 days), use raw 5-minute data
-	if daysAgo <= 7 {
-		var history []models.PriceHistory
-		err := r.db.Where("item_id = ? AND timestamp BETWEEN ? AND ?", itemID, startTime, endTime).
-			Order("timestamp ASC").
-			Find(&history).Error
-		return history, err
+// GetLatestSnapshot reconstructs the latest prices for every item in a game mode
+// from the newest stored row of each, and returns when they were last fetched
+// and the ID of that fetch's snapshot (0 for data stored before snapshots were
+// recorded)
+// Returns an empty map and the zero time if nothing has been stored yet
+func (r *Repository) GetLatestSnapshot(ctx context.Context, mode string) (map[string]models.ItemPrice, time.Time, int64, error) {
+	records, err := r.GetLatestStoredPrices(ctx, mode)
+	if err != nil || len(records) == 0 {
+		return map[string]models.ItemPrice{}, time.Time{}, 0, err
 	}
-	
-	// If requesting 8-90 days, use hourly aggregates
-	if daysAgo <= 90 {
-		var hourlyData []models.PriceHistoryHourly
-		err := r.db.Where("item_id = ? AND hour_timestamp BETWEEN ? AND ?", itemID, startTime, endTime).
-			Order("hour_timestamp ASC").
-			Find(&hourlyData).Error
-		
-		// Convert to PriceHistory format
-		history := make([]models.PriceHistory, len(hourlyData))
-		for i, h := range hourlyData {
-			history[i] = models.PriceHistory{
-				ItemID:     h.ItemID,
-				High:       h.AvgHigh,
-				Low:        h.AvgLow,
-				HighVolume: h.TotalHighVolume,
-				LowVolume:  h.TotalLowVolume,
-				Timestamp:  h.HourTimestamp,
-			}
+
+	var fetchedAt time.Time
+	prices := make(map[string]models.ItemPrice, len(records))
+	for _, record := range records {
+		prices[fmt.Sprintf("%d", record.ItemID)] = models.ItemPrice{
+			ID:         record.ItemID,
+			High:       record.High,
+			HighTime:   record.HighTime,
+			Low:        record.Low,
+			LowTime:    record.LowTime,
+			HighVolume: record.HighVolume,
+			LowVolume:  record.LowVolume,
 		}
-		return history, err
-	}
-	
-	// For 90+ days, use daily aggregates
-	var dailyData []models.PriceHistoryDaily
-	err := r.db.Where("item_id = ? AND day_date BETWEEN ? AND ?", itemID, startTime.Truncate(24*time.Hour), endTime.Truncate(24*time.Hour)).
-		Order("day_date ASC").
-		Find(&dailyData).Error
-	
-	// Convert to PriceHistory format
-	history := make([]models.PriceHistory, len(dailyData))
-	for i, d := range dailyData {
-		history[i] = models.PriceHistory{
-			ItemID:     d.ItemID,
-			High:       d.AvgHigh,
-			Low:        d.AvgLow,
-			HighVolume: d.TotalHighVolume,
-			LowVolume:  d.TotalLowVolume,
-			Timestamp:  d.DayDate,
+		if record.Timestamp.After(fetchedAt) {
+			fetchedAt = record.Timestamp
 		}
 	}
-	return history, err
+
+	// A fetch that found nothing new is still the newest data
+	snapshot, err := r.GetLastPriceSnapshot(ctx, mode)
+	if err != nil {
+		return nil, time.Time{}, 0, err
+	}
+	var snapshotID int64
+	if snapshot != nil && !snapshot.FetchedAt.Before(fetchedAt) {
+		fetchedAt = snapshot.FetchedAt
+		snapshotID = snapshot.ID
+	}
+	return prices, fetchedAt, snapshotID, nil
 }
 
 // GetPriceChange calculates price change for an item over a time period
-func (r *Repository) GetPriceChange(itemID int, duration time.Duration) (*models.PriceChangeResponse, error) {
+func (r *Repository) GetPriceChange(ctx context.Context, mode string, itemID int, duration time.Duration) (*models.PriceChangeResponse, error) {
 	now := time.Now().UTC()
 	startTime := now.Add(-duration)
 
 	var current, previous models.PriceHistory
 
 	// Get current (most recent) price
-	if err := r.db.Where("item_id = ?", itemID).
-		Order("timestamp DESC").
+	if err := r.db.WithContext(ctx).Where("game_mode = ? AND item_id = ?", mode, itemID).
+		Order("timestamp DESC, id DESC").
 		First(&current).Error; err != nil {
 		return nil, fmt.Errorf("no current price data: %w", err)
 	}
 
-	// Get previous price (closest to start time)
-	if err := r.db.Where("item_id = ? AND timestamp >= ?", itemID, startTime).
-		Order("timestamp ASC").
-		First(&previous).Error; err != nil {
+	// Get previous price: the one in effect at the start time, as rows are
+	// only stored when an item changes, or else the first one after it
+	err := r.db.WithContext(ctx).Where("game_mode = ? AND item_id = ? AND timestamp <= ? AND timestamp > ?", mode, itemID, startTime, startTime.Add(-models.KeyframeInterval)).
+		Order("timestamp DESC, id DESC").
+		First(&previous).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = r.db.WithContext(ctx).Where("game_mode = ? AND item_id = ? AND timestamp > ?", mode, itemID, startTime).
+			Order("timestamp ASC").
+			First(&previous).Error
+	}
+	if err != nil {
 		return nil, fmt.Errorf("no historical price data: %w", err)
 	}
 
@@ -170,7 +132,9 @@ func (r *Repository) GetPriceChange(itemID int, duration time.Duration) (*models
 }
 
 // GetPriceStats calculates statistical data for an item
-func (r *Repository) GetPriceStats(itemID int, startTime, endTime time.Time) (*models.PriceStats, error) {
+// Statistics cover every 5-minute point in the range, with prices carried
+// forward from the last change rather than only the stored rows.
+func (r *Repository) GetPriceStats(ctx context.Context, mode string, itemID int, startTime, endTime time.Time) (*models.PriceStats, error) {
 	var stats struct {
 		AvgHigh    float64
 		AvgLow     float64
@@ -179,32 +143,27 @@ func (r *Repository) GetPriceStats(itemID int, startTime, endTime time.Time) (*m
 		MinHigh    int64
 		MinLow     int64
 		DataPoints int64
+		Volatility float64 // Standard deviation of high prices
 	}
 
-	err := r.db.Model(&models.PriceHistory{}).
-		Select(`
-			AVG(high) as avg_high,
-			AVG(low) as avg_low,
-			MAX(high) as max_high,
-			MAX(low) as max_low,
-			MIN(high) as min_high,
-			MIN(low) as min_low,
-			COUNT(*) as data_points
-		`).
-		Where("item_id = ? AND timestamp BETWEEN ? AND ?", itemID, startTime, endTime).
-		Scan(&stats).Error
+	series, args := seriesQuery(historyTiers[0], mode, itemID, startTime, endTime)
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT
+			COALESCE(AVG(high), 0) as avg_high,
+			COALESCE(AVG(low), 0) as avg_low,
+			COALESCE(MAX(high), 0) as max_high,
+			COALESCE(MAX(low), 0) as max_low,
+			COALESCE(MIN(high), 0) as min_high,
+			COALESCE(MIN(low), 0) as min_low,
+			COUNT(*) as data_points,
+			COALESCE(STDDEV(high), 0) as volatility
+		FROM (`+series+`) AS s
+	`, args...).Scan(&stats).Error
 
 	if err != nil {
 		return nil, err
 	}
 
-	// Calculate volatility (standard deviation of high prices)
-	var volatility float64
-	r.db.Model(&models.PriceHistory{}).
-		Select("STDDEV(high)").
-		Where("item_id = ? AND timestamp BETWEEN ? AND ?", itemID, startTime, endTime).
-		Scan(&volatility)
-
 	return &models.PriceStats{
 		ItemID:     itemID,
 		AvgHigh:    stats.AvgHigh,
@@ -213,7 +172,7 @@ func (r *Repository) GetPriceStats(itemID int, startTime, endTime time.Time) (*m
 		MaxLow:     stats.MaxLow,
 		MinHigh:    stats.MinHigh,
 		MinLow:     stats.MinLow,
-		Volatility: volatility,
+		Volatility: stats.Volatility,
 		DataPoints: stats.DataPoints,
 		StartTime:  startTime,
 		EndTime:    endTime,
@@ -221,7 +180,7 @@ func (r *Repository) GetPriceStats(itemID int, startTime, endTime time.Time) (*m
 }
 
 // GetTopGainers returns items with the highest price increases
-func (r *Repository) GetTopGainers(limit int, duration time.Duration) ([]models.PriceChangeResponse, error) {
+func (r *Repository) GetTopGainers(ctx context.Context, mode string, limit int, duration time.Duration) ([]models.PriceChangeResponse, error) {
 	now := time.Now().UTC()
 	startTime := now.Add(-duration)
 
@@ -230,15 +189,17 @@ func (r *Repository) GetTopGainers(limit int, duration time.Duration) ([]models.
 			SELECT DISTINCT ON (item_id)
 				item_id, high, low, timestamp
 			FROM price_history
-			WHERE timestamp >= $1
-			ORDER BY item_id, timestamp DESC
+			WHERE timestamp > $1 AND game_mode = $4
+			ORDER BY item_id, timestamp DESC, id DESC
 		),
 		previous_prices AS (
+			-- Rows are only stored when an item changes, so the price in effect
+			-- at the start time is the newest row at or before it
 			SELECT DISTINCT ON (item_id)
 				item_id, high as prev_high, low as prev_low
 			FROM price_history
-			WHERE timestamp >= $2
-			ORDER BY item_id, timestamp ASC
+			WHERE timestamp <= $2 AND timestamp > $5 AND game_mode = $4
+			ORDER BY item_id, timestamp DESC, id DESC
 		)
 		SELECT 
 			c.item_id,
@@ -271,7 +232,7 @@ func (r *Repository) GetTopGainers(limit int, duration time.Duration) ([]models.
 		Timestamp      time.Time
 	}
 
-	err := r.db.Raw(query, now, startTime, limit).Scan(&results).Error
+	err := r.db.WithContext(ctx).Raw(query, now.Add(-models.KeyframeInterval), startTime, limit, mode, startTime.Add(-models.KeyframeInterval)).Scan(&results).Error
 	if err != nil {
 		return nil, err
 	}
@@ -297,7 +258,7 @@ func (r *Repository) GetTopGainers(limit int, duration time.Duration) ([]models.
 }
 
 // GetTopByVolume returns items with the highest trading volume
-func (r *Repository) GetTopByVolume(limit int, duration time.Duration) ([]struct {
+func (r *Repository) GetTopByVolume(ctx context.Context, mode string, limit int, duration time.Duration) ([]struct {
 	ItemID      int   `json:"item_id"`
 	TotalVolume int64 `json:"total_volume"`
 	AvgHigh     int64 `json:"avg_high"`
@@ -312,9 +273,10 @@ func (r *Repository) GetTopByVolume(limit int, duration time.Duration) ([]struct
 		AvgLow      int64 `json:"avg_low"`
 	}
 	
-	err := r.db.Model(&models.PriceHistory{}).
-		Select("item_id, SUM(high_volume + low_volume) as total_volume, AVG(high) as avg_high, AVG(low) as avg_low").
-		Where("timestamp > ?", cutoff).
+	// Volumes come from the 5-minute averages; the /latest snapshots carry none
+	err := r.db.WithContext(ctx).Model(&models.PriceAverage{}).
+		Select("item_id, SUM(high_volume + low_volume) as total_volume, COALESCE(AVG(NULLIF(avg_high, 0)), 0)::bigint as avg_high, COALESCE(AVG(NULLIF(avg_low, 0)), 0)::bigint as avg_low").
+		Where("game_mode = ? AND timestep = ? AND timestamp > ?", mode, models.Timestep5m, cutoff).
 		Group("item_id").
 		Having("SUM(high_volume + low_volume) > 0").
 		Order("total_volume DESC").
@@ -324,57 +286,140 @@ func (r *Repository) GetTopByVolume(limit int, duration time.Duration) ([]struct
 	return results, err
 }
 
-// AggregateToHourly aggregates 5-minute data into hourly buckets
-func (r *Repository) AggregateToHourly(startTime, endTime time.Time) (int64, error) {
-	// Aggregate data for each hour in the range
+// AggregateToHourly aggregates 5-minute data into hourly buckets for every game mode
+// Raw prices are only stored when an item changes, so the hour is aggregated
+// over the same carry-forward 5-minute grid reads use: every grid point takes
+// the newest row at or before its end, seeded with the last row before the
+// range and looking back at most models.KeyframeInterval. Every item with a
+// price to carry gets a bucket for every hour whether or not it traded,
+// averages weigh each 5 minutes equally, data_points counts grid points and
+// volumes only count in the 5 minutes they were stored in.
+// Buckets that already exist are recomputed, so raw data that arrived late
+// (e.g. from a backfill) corrects the hour instead of being ignored.
+// With TimescaleDB the buckets go to the hourly hypertable.
+func (r *Repository) AggregateToHourly(ctx context.Context, startTime, endTime time.Time) (int64, error) {
 	query := `
-		INSERT INTO price_history_hourly (
-			item_id, avg_high, avg_low, max_high, min_low,
+		INSERT INTO ` + r.aggregateTable("price_history_hourly") + ` (
+			game_mode, item_id, avg_high, avg_low, max_high, min_high, max_low, min_low,
 			opening_high, opening_low, closing_high, closing_low,
 			total_high_volume, total_low_volume, data_points, hour_timestamp
 		)
-		SELECT 
+		SELECT
+			game_mode,
 			item_id,
 			AVG(high) as avg_high,
 			AVG(low) as avg_low,
 			MAX(high) as max_high,
+			MIN(high) as min_high,
+			MAX(low) as max_low,
 			MIN(low) as min_low,
-			FIRST_VALUE(high) OVER (PARTITION BY item_id, date_trunc('hour', timestamp) ORDER BY timestamp) as opening_high,
-			FIRST_VALUE(low) OVER (PARTITION BY item_id, date_trunc('hour', timestamp) ORDER BY timestamp) as opening_low,
-			LAST_VALUE(high) OVER (PARTITION BY item_id, date_trunc('hour', timestamp) ORDER BY timestamp ROWS BETWEEN UNBOUNDED PRECEDING AND UNBOUNDED FOLLOWING) as closing_high,
-			LAST_VALUE(low) OVER (PARTITION BY item_id, date_trunc('hour', timestamp) ORDER BY timestamp ROWS BETWEEN UNBOUNDED PRECEDING AND UNBOUNDED FOLLOWING) as closing_low,
+			(array_agg(high ORDER BY t))[1] as opening_high,
+			(array_agg(low ORDER BY t))[1] as opening_low,
+			(array_agg(high ORDER BY t DESC))[1] as closing_high,
+			(array_agg(low ORDER BY t DESC))[1] as closing_low,
 			SUM(high_volume) as total_high_volume,
 			SUM(low_volume) as total_low_volume,
 			COUNT(*) as data_points,
-			date_trunc('hour', timestamp) as hour_timestamp
-		FROM price_history
-		WHERE timestamp >= ? AND timestamp < ?
-		GROUP BY item_id, date_trunc('hour', timestamp)
-		ON CONFLICT DO NOTHING
+			date_trunc('hour', t) as hour_timestamp
+		FROM (` + gridQuery + `) grid
+		GROUP BY game_mode, item_id, date_trunc('hour', t)
+		ON CONFLICT (game_mode, item_id, hour_timestamp) DO UPDATE SET
+			avg_high = EXCLUDED.avg_high,
+			avg_low = EXCLUDED.avg_low,
+			max_high = EXCLUDED.max_high,
+			min_high = EXCLUDED.min_high,
+			max_low = EXCLUDED.max_low,
+			min_low = EXCLUDED.min_low,
+			opening_high = EXCLUDED.opening_high,
+			opening_low = EXCLUDED.opening_low,
+			closing_high = EXCLUDED.closing_high,
+			closing_low = EXCLUDED.closing_low,
+			total_high_volume = EXCLUDED.total_high_volume,
+			total_low_volume = EXCLUDED.total_low_volume,
+			data_points = EXCLUDED.data_points
 	`
-	
-	result := r.db.Exec(query, startTime, endTime)
+
+	result := r.db.WithContext(ctx).Exec(query, gridArgs(startTime, endTime)...)
 	return result.RowsAffected, result.Error
 }
 
-// AggregateToDaily aggregates hourly data into daily buckets
-func (r *Repository) AggregateToDaily(startTime, endTime time.Time) (int64, error) {
+// gridQuery selects every item's carry-forward 5-minute grid points within a
+// range (game_mode, item_id, high, low, high_volume, low_volume, t), taking
+// the arguments from gridArgs
+// Rows are the range's raw rows plus each item's last row before it. Only the
+// newest row of each 5 minutes counts; it holds from its own grid point until
+// the next row's, the end of the range or one keyframe interval later,
+// whichever comes first, and its volumes only count at its own grid point.
+const gridQuery = `
+	WITH stored AS (
+		SELECT game_mode, item_id, high, low, high_volume, low_volume, timestamp, id
+		FROM price_history
+		WHERE timestamp >= ? AND timestamp < ?
+		UNION ALL
+		(
+			SELECT DISTINCT ON (game_mode, item_id) game_mode, item_id, high, low, high_volume, low_volume, timestamp, id
+			FROM price_history
+			WHERE timestamp >= ?::timestamptz - ? * INTERVAL '1 second' AND timestamp < ?
+			ORDER BY game_mode, item_id, timestamp DESC, id DESC
+		)
+	),
+	samples AS (
+		SELECT DISTINCT ON (game_mode, item_id, bucket) *
+		FROM (
+			SELECT stored.*, to_timestamp(floor(extract(epoch FROM stored.timestamp) / 300) * 300) AS bucket
+			FROM stored
+		) s
+		ORDER BY game_mode, item_id, bucket, timestamp DESC, id DESC
+	),
+	spans AS (
+		SELECT samples.*, LEAD(bucket, 1, ?::timestamptz) OVER (PARTITION BY game_mode, item_id ORDER BY bucket) AS next_bucket
+		FROM samples
+	)
+	SELECT s.game_mode, s.item_id, s.high, s.low,
+		CASE WHEN g.t = s.bucket THEN s.high_volume ELSE 0 END AS high_volume,
+		CASE WHEN g.t = s.bucket THEN s.low_volume ELSE 0 END AS low_volume,
+		g.t
+	FROM spans s
+	CROSS JOIN LATERAL generate_series(
+		GREATEST(s.bucket, ?::timestamptz),
+		LEAST(s.next_bucket - INTERVAL '5 minutes', s.timestamp + ? * INTERVAL '1 second'),
+		INTERVAL '5 minutes'
+	) AS g(t)
+`
+
+// gridArgs returns the arguments of gridQuery for [startTime, endTime), which
+// must start on a 5-minute boundary
+func gridArgs(startTime, endTime time.Time) []interface{} {
+	return []interface{}{startTime, endTime, startTime, keyframeSeconds, startTime, endTime, startTime, keyframeSeconds}
+}
+
+// AggregateToDaily aggregates hourly data into daily buckets for every game mode
+// Like the hourly rollup, existing days are recomputed from their hours
+// With TimescaleDB it refreshes the daily continuous aggregate instead.
+func (r *Repository) AggregateToDaily(ctx context.Context, startTime, endTime time.Time) (int64, error) {
+	if r.timescale {
+		return r.refreshAggregate(ctx, "price_history_daily_cagg", "day_bucket", startTime, endTime)
+	}
+
 	query := `
 		INSERT INTO price_history_daily (
-			item_id, avg_high, avg_low, max_high, min_low,
+			game_mode, item_id, avg_high, avg_low, max_high, min_high, max_low, min_low,
 			opening_high, opening_low, closing_high, closing_low,
 			total_high_volume, total_low_volume, volatility, data_points, day_date
 		)
 		SELECT 
+			game_mode,
 			item_id,
 			AVG(avg_high) as avg_high,
 			AVG(avg_low) as avg_low,
 			MAX(max_high) as max_high,
+			MIN(min_high) as min_high,
+			MAX(max_low) as max_low,
 			MIN(min_low) as min_low,
-			FIRST_VALUE(opening_high) OVER (PARTITION BY item_id, DATE(hour_timestamp) ORDER BY hour_timestamp) as opening_high,
-			FIRST_VALUE(opening_low) OVER (PARTITION BY item_id, DATE(hour_timestamp) ORDER BY hour_timestamp) as opening_low,
-			LAST_VALUE(closing_high) OVER (PARTITION BY item_id, DATE(hour_timestamp) ORDER BY hour_timestamp ROWS BETWEEN UNBOUNDED PRECEDING AND UNBOUNDED FOLLOWING) as closing_high,
-			LAST_VALUE(closing_low) OVER (PARTITION BY item_id, DATE(hour_timestamp) ORDER BY hour_timestamp ROWS BETWEEN UNBOUNDED PRECEDING AND UNBOUNDED FOLLOWING) as closing_low,
+			(array_agg(opening_high ORDER BY hour_timestamp))[1] as opening_high,
+			(array_agg(opening_low ORDER BY hour_timestamp))[1] as opening_low,
+			(array_agg(closing_high ORDER BY hour_timestamp DESC))[1] as closing_high,
+			(array_agg(closing_low ORDER BY hour_timestamp DESC))[1] as closing_low,
 			SUM(total_high_volume) as total_high_volume,
 			SUM(total_low_volume) as total_low_volume,
 			CASE WHEN AVG(avg_high) > 0 THEN (MAX(max_high) - MIN(min_low))::float / AVG(avg_high) ELSE 0 END as volatility,
@@ -382,17 +427,109 @@ func (r *Repository) AggregateToDaily(startTime, endTime time.Time) (int64, erro
 			DATE(hour_timestamp) as day_date
 		FROM price_history_hourly
 		WHERE hour_timestamp >= ? AND hour_timestamp < ?
-		GROUP BY item_id, DATE(hour_timestamp)
-		ON CONFLICT (item_id, day_date) DO NOTHING
+		GROUP BY game_mode, item_id, DATE(hour_timestamp)
+		ON CONFLICT (game_mode, item_id, day_date) DO UPDATE SET
+			avg_high = EXCLUDED.avg_high,
+			avg_low = EXCLUDED.avg_low,
+			max_high = EXCLUDED.max_high,
+			min_high = EXCLUDED.min_high,
+			max_low = EXCLUDED.max_low,
+			min_low = EXCLUDED.min_low,
+			opening_high = EXCLUDED.opening_high,
+			opening_low = EXCLUDED.opening_low,
+			closing_high = EXCLUDED.closing_high,
+			closing_low = EXCLUDED.closing_low,
+			total_high_volume = EXCLUDED.total_high_volume,
+			total_low_volume = EXCLUDED.total_low_volume,
+			volatility = EXCLUDED.volatility,
+			data_points = EXCLUDED.data_points
 	`
 	
-	result := r.db.Exec(query, startTime, endTime)
+	result := r.db.WithContext(ctx).Exec(query, startTime, endTime)
+	return result.RowsAffected, result.Error
+}
+
+// AggregateToWeekly aggregates daily data into Monday-to-Sunday buckets for every game mode
+func (r *Repository) AggregateToWeekly(ctx context.Context, startTime, endTime time.Time) (int64, error) {
+	return r.aggregateDaily(ctx, "price_history_weekly", "week_start", "week", startTime, endTime)
+}
+
+// AggregateToMonthly aggregates daily data into calendar month buckets for every game mode
+func (r *Repository) AggregateToMonthly(ctx context.Context, startTime, endTime time.Time) (int64, error) {
+	return r.aggregateDaily(ctx, "price_history_monthly", "month_start", "month", startTime, endTime)
+}
+
+// aggregateDaily rolls days up into a coarser table whose bucket column holds
+// date_trunc(unit, day_date); existing buckets are recomputed like the other tiers
+func (r *Repository) aggregateDaily(ctx context.Context, table, column, unit string, startTime, endTime time.Time) (int64, error) {
+	query := fmt.Sprintf(`
+		INSERT INTO %[1]s (
+			game_mode, item_id, avg_high, avg_low, max_high, min_high, max_low, min_low,
+			opening_high, opening_low, closing_high, closing_low,
+			total_high_volume, total_low_volume, volatility, data_points, %[2]s
+		)
+		SELECT
+			game_mode,
+			item_id,
+			AVG(avg_high) as avg_high,
+			AVG(avg_low) as avg_low,
+			MAX(max_high) as max_high,
+			MIN(min_high) as min_high,
+			MAX(max_low) as max_low,
+			MIN(min_low) as min_low,
+			(array_agg(opening_high ORDER BY day_date))[1] as opening_high,
+			(array_agg(opening_low ORDER BY day_date))[1] as opening_low,
+			(array_agg(closing_high ORDER BY day_date DESC))[1] as closing_high,
+			(array_agg(closing_low ORDER BY day_date DESC))[1] as closing_low,
+			SUM(total_high_volume) as total_high_volume,
+			SUM(total_low_volume) as total_low_volume,
+			CASE WHEN AVG(avg_high) > 0 THEN (MAX(max_high) - MIN(min_low))::float / AVG(avg_high) ELSE 0 END as volatility,
+			SUM(data_points) as data_points,
+			date_trunc('%[3]s', day_date)::date as %[2]s
+		FROM price_history_daily
+		WHERE day_date >= ? AND day_date < ?
+		GROUP BY game_mode, item_id, date_trunc('%[3]s', day_date)
+		ON CONFLICT (game_mode, item_id, %[2]s) DO UPDATE SET
+			avg_high = EXCLUDED.avg_high,
+			avg_low = EXCLUDED.avg_low,
+			max_high = EXCLUDED.max_high,
+			min_high = EXCLUDED.min_high,
+			max_low = EXCLUDED.max_low,
+			min_low = EXCLUDED.min_low,
+			opening_high = EXCLUDED.opening_high,
+			opening_low = EXCLUDED.opening_low,
+			closing_high = EXCLUDED.closing_high,
+			closing_low = EXCLUDED.closing_low,
+			total_high_volume = EXCLUDED.total_high_volume,
+			total_low_volume = EXCLUDED.total_low_volume,
+			volatility = EXCLUDED.volatility,
+			data_points = EXCLUDED.data_points
+	`, table, column, unit)
+
+	result := r.db.WithContext(ctx).Exec(query, startTime, endTime)
 	return result.RowsAffected, result.Error
 }
 
-// DeleteOldPriceHistory deletes price history older than the given date
-func (r *Repository) DeleteOldPriceHistory(cutoffDate time.Time) (int64, error) {
-	result := r.db.Where("timestamp < ?", cutoffDate).Delete(&models.PriceHistory{})
+// DeleteOldDailyData deletes daily aggregates older than the given date
+func (r *Repository) DeleteOldDailyData(ctx context.Context, cutoffDate time.Time) (int64, error) {
+	if r.timescale {
+		return r.deleteOldAggregates(ctx, "price_history_daily_cagg", "day_bucket", "price_history_daily_base", "day_date", cutoffDate)
+	}
+	return r.deleteBefore(ctx, "price_history_daily", "day_date", cutoffDate)
+}
+
+// DeleteOldWeeklyData deletes weekly aggregates older than the given date
+func (r *Repository) DeleteOldWeeklyData(ctx context.Context, cutoffDate time.Time) (int64, error) {
+	return r.deleteBefore(ctx, "price_history_weekly", "week_start", cutoffDate)
+}
+
+// DeleteOldMonthlyData deletes monthly aggregates older than the given date
+func (r *Repository) DeleteOldMonthlyData(ctx context.Context, cutoffDate time.Time) (int64, error) {
+	return r.deleteBefore(ctx, "price_history_monthly", "month_start", cutoffDate)
+}
+
+func (r *Repository) deleteBefore(ctx context.Context, table, column string, cutoffDate time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Exec(fmt.Sprintf("DELETE FROM %s WHERE %s < ?", table, column), cutoffDate)
 	if result.Error != nil {
 		return 0, result.Error
 	}
@@ -400,8 +537,11 @@ func (r *Repository) DeleteOldPriceHistory(cutoffDate time.Time) (int64, error)
 }
 
 // DeleteOldHourlyData deletes hourly aggregates older than the given date
-func (r *Repository) DeleteOldHourlyData(cutoffDate time.Time) (int64, error) {
-	result := r.db.Exec("DELETE FROM price_history_hourly WHERE hour_timestamp < ?", cutoffDate)
+func (r *Repository) DeleteOldHourlyData(ctx context.Context, cutoffDate time.Time) (int64, error) {
+	if r.timescale {
+		return r.deleteOldAggregates(ctx, "price_history_hourly_hypertable", "hour_timestamp", "price_history_hourly_base", "hour_timestamp", cutoffDate)
+	}
+	result := r.db.WithContext(ctx).Exec("DELETE FROM price_history_hourly WHERE hour_timestamp < ?", cutoffDate)
 	if result.Error != nil {
 		return 0, result.Error
 	}
@@ -417,24 +557,24 @@ type DatabaseStats struct {
 }
 
 // GetDatabaseStats returns statistics about the price_history table
-func (r *Repository) GetDatabaseStats() (*DatabaseStats, error) {
+func (r *Repository) GetDatabaseStats(ctx context.Context) (*DatabaseStats, error) {
 	var stats DatabaseStats
 	
 	// Get total record count
-	if err := r.db.Model(&models.PriceHistory{}).Count(&stats.TotalRecords).Error; err != nil {
+	if err := r.db.WithContext(ctx).Model(&models.PriceHistory{}).Count(&stats.TotalRecords).Error; err != nil {
 		return nil, err
 	}
 	
 	// Get oldest record
 	var oldest models.PriceHistory
-	if err := r.db.Order("timestamp ASC").First(&oldest).Error; err != nil && err != gorm.ErrRecordNotFound {
+	if err := r.db.WithContext(ctx).Order("timestamp ASC").First(&oldest).Error; err != nil && err != gorm.ErrRecordNotFound {
 		return nil, err
 	}
 	stats.OldestRecord = oldest.Timestamp
 	
 	// Get newest record
 	var newest models.PriceHistory
-	if err := r.db.Order("timestamp DESC").First(&newest).Error; err != nil && err != gorm.ErrRecordNotFound {
+	if err := r.db.WithContext(ctx).Order("timestamp DESC").First(&newest).Error; err != nil && err != gorm.ErrRecordNotFound {
 		return nil, err
 	}
 	stats.NewestRecord = newest.Timestamp
@@ -446,7 +586,7 @@ func (r *Repository) GetDatabaseStats() (*DatabaseStats, error) {
 }
 
 // GetTopLosers returns items with the highest price decreases
-func (r *Repository) GetTopLosers(limit int, duration time.Duration) ([]models.PriceChangeResponse, error) {
+func (r *Repository) GetTopLosers(ctx context.Context, mode string, limit int, duration time.Duration) ([]models.PriceChangeResponse, error) {
 	now := time.Now().UTC()
 	startTime := now.Add(-duration)
 
@@ -455,15 +595,17 @@ func (r *Repository) GetTopLosers(limit int, duration time.Duration) ([]models.P
 			SELECT DISTINCT ON (item_id)
 				item_id, high, low, timestamp
 			FROM price_history
-			WHERE timestamp >= $1
-			ORDER BY item_id, timestamp DESC
+			WHERE timestamp > $1 AND game_mode = $4
+			ORDER BY item_id, timestamp DESC, id DESC
 		),
 		previous_prices AS (
+			-- Rows are only stored when an item changes, so the price in effect
+			-- at the start time is the newest row at or before it
 			SELECT DISTINCT ON (item_id)
 				item_id, high as prev_high, low as prev_low
 			FROM price_history
-			WHERE timestamp >= $2
-			ORDER BY item_id, timestamp ASC
+			WHERE timestamp <= $2 AND timestamp > $5 AND game_mode = $4
+			ORDER BY item_id, timestamp DESC, id DESC
 		)
 		SELECT 
 			c.item_id,
@@ -496,7 +638,7 @@ func (r *Repository) GetTopLosers(limit int, duration time.Duration) ([]models.P
 		Timestamp      time.Time
 	}
 
-	err := r.db.Raw(query, now, startTime, limit).Scan(&results).Error
+	err := r.db.WithContext(ctx).Raw(query, now.Add(-models.KeyframeInterval), startTime, limit, mode, startTime.Add(-models.KeyframeInterval)).Scan(&results).Error
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,86 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"osrs-price-api/internal/models"
+)
+
+// Item IDs and times the repository tests write under, far away from real data
+const (
+	testItemTraded = 2_000_001
+	testItemStale  = 2_000_002
+)
+
+var testHour = time.Date(2002, 3, 1, 10, 0, 0, 0, time.UTC)
+
+func TestAggregateToHourlyCarriesPricesForward(t *testing.T) {
+	repo, db := newTestRepository(t)
+	ctx := context.Background()
+
+	cleanup := func() {
+		for _, table := range []string{"price_history", repo.aggregateTable("price_history_hourly")} {
+			if err := db.Exec("DELETE FROM "+table+" WHERE item_id IN ?", []int{testItemTraded, testItemStale}).Error; err != nil {
+				t.Errorf("cleaning up %s: %v", table, err)
+			}
+		}
+	}
+	cleanup()
+	t.Cleanup(cleanup)
+
+	if _, err := repo.EnsurePriceHistoryPartitions(ctx, testHour.Add(-48*time.Hour), testHour.Add(2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	rows := []struct {
+		itemID          int
+		high, low       int64
+		highVol, lowVol int64
+		timestamp       time.Time
+	}{
+		// Stored an hour before the range: seeds its first half hour
+		{testItemTraded, 100, 90, 1, 1, testHour.Add(-time.Hour)},
+		// The only change in the range; carried through the second hour
+		{testItemTraded, 200, 180, 5, 6, testHour.Add(30 * time.Minute)},
+		// Older than a keyframe interval: nothing to carry forward
+		{testItemStale, 500, 400, 1, 1, testHour.Add(-models.KeyframeInterval - time.Hour)},
+	}
+	for _, row := range rows {
+		err := db.Exec(`INSERT INTO price_history (game_mode, item_id, high, low, high_volume, low_volume, timestamp, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, NOW())`,
+			models.GameModeMain, row.itemID, row.high, row.low, row.highVol, row.lowVol, row.timestamp).Error
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := repo.AggregateToHourly(ctx, testHour, testHour.Add(2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	var hours []models.PriceHistoryHourly
+	err := db.Raw("SELECT * FROM price_history_hourly WHERE item_id IN ? ORDER BY item_id, hour_timestamp",
+		[]int{testItemTraded, testItemStale}).Scan(&hours).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hours) != 2 {
+		t.Fatalf("got %d hourly buckets, want 2 (one per hour for the traded item, none for the stale one)", len(hours))
+	}
+
+	// Six 5-minute points at 100/90, then six at 200/180
+	first := hours[0]
+	if first.ItemID != testItemTraded || first.AvgHigh != 150 || first.AvgLow != 135 ||
+		first.MinHigh != 100 || first.MaxHigh != 200 || first.OpeningHigh != 100 || first.ClosingHigh != 200 ||
+		first.TotalHighVolume != 5 || first.TotalLowVolume != 6 || first.DataPoints != 12 {
+		t.Errorf("first hour = %+v", first)
+	}
+
+	// No trades at all: the last price carried through every point, no volume
+	second := hours[1]
+	if second.AvgHigh != 200 || second.AvgLow != 180 || second.OpeningHigh != 200 || second.ClosingLow != 180 ||
+		second.TotalHighVolume != 0 || second.TotalLowVolume != 0 || second.DataPoints != 12 {
+		t.Errorf("second hour = %+v", second)
+	}
+}
@@ -0,0 +1,95 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"osrs-price-api/internal/models"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm/clause"
+)
+
+// GetRollupWatermark returns the end of the newest closed bucket for a rollup tier
+// Returns the zero time if the tier has never been rolled up
+func (r *Repository) GetRollupWatermark(ctx context.Context, tier string) (time.Time, error) {
+	var watermarks []models.RollupWatermark
+	if err := r.db.WithContext(ctx).Where("tier = ?", tier).Limit(1).Find(&watermarks).Error; err != nil {
+		return time.Time{}, err
+	}
+	if len(watermarks) == 0 {
+		return time.Time{}, nil
+	}
+	return watermarks[0].Watermark.UTC(), nil
+}
+
+// AdvanceRollupWatermark records that every bucket of a tier before to is
+// closed, provided its watermark is still from (zero if it had none), and
+// reports whether it did
+// A watermark that moved in the meantime was rewound for late rows, which
+// must win over the buckets just closed.
+func (r *Repository) AdvanceRollupWatermark(ctx context.Context, tier string, from, to time.Time) (bool, error) {
+	if from.IsZero() {
+		result := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.RollupWatermark{Tier: tier, Watermark: to})
+		return result.RowsAffected == 1, result.Error
+	}
+
+	result := r.db.WithContext(ctx).Model(&models.RollupWatermark{}).
+		Where("tier = ? AND watermark = ?", tier, from).
+		Updates(map[string]interface{}{"watermark": to, "updated_at": time.Now().UTC()})
+	return result.RowsAffected == 1, result.Error
+}
+
+// rollupWatermarkTiers are the tiers that keep a rollup watermark
+var rollupWatermarkTiers = []string{models.ResolutionHourly, models.ResolutionDaily, models.ResolutionWeekly, models.ResolutionMonthly}
+
+// pgxExecer is satisfied by pgx.Tx and *pgx.Conn
+type pgxExecer interface {
+	Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error)
+}
+
+// rewindRollupWatermarks moves back the watermark of every tier that already
+// closed the bucket containing t, so the rollup job aggregates it again, along
+// with every later bucket the rows at t carry forward into
+func rewindRollupWatermarks(ctx context.Context, tx pgxExecer, t time.Time) error {
+	for _, tier := range rollupWatermarkTiers {
+		start := models.BucketStart(tier, t)
+		tag, err := tx.Exec(ctx, `
+			UPDATE rollup_watermarks SET watermark = $1, updated_at = NOW()
+			WHERE tier = $2 AND watermark > $1
+		`, start, tier)
+		if err != nil {
+			return fmt.Errorf("rewinding %s rollup watermark: %w", tier, err)
+		}
+		if tag.RowsAffected() > 0 {
+			log.Printf("Rewound %s rollup watermark to %s for late rows", tier, start.Format(time.RFC3339))
+		}
+	}
+	return nil
+}
+
+// GetRollupSourceStart returns the oldest timestamp in the table a tier is rolled up from
+// (raw history for hourly, hourly buckets for daily, daily buckets for weekly
+// and monthly), or the zero time if it is empty
+func (r *Repository) GetRollupSourceStart(ctx context.Context, tier string) (time.Time, error) {
+	var query string
+	switch tier {
+	case models.ResolutionHourly:
+		query = "SELECT MIN(timestamp) FROM price_history"
+	case models.ResolutionDaily:
+		query = "SELECT MIN(hour_timestamp) FROM price_history_hourly"
+	case models.ResolutionWeekly, models.ResolutionMonthly:
+		query = "SELECT MIN(day_date)::timestamp FROM price_history_daily"
+	default:
+		return time.Time{}, fmt.Errorf("unknown rollup tier: %s", tier)
+	}
+
+	var start *time.Time
+	if err := r.db.WithContext(ctx).Raw(query).Scan(&start).Error; err != nil || start == nil {
+		return time.Time{}, err
+	}
+	return start.UTC(), nil
+}
@@ -0,0 +1,72 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"osrs-price-api/internal/models"
+
+	"github.com/jackc/pgx/v5/stdlib"
+	"gorm.io/gorm"
+)
+
+func TestRewindStopsRollupWatermarkAdvance(t *testing.T) {
+	shared, db := newTestRepository(t)
+	ctx := context.Background()
+
+	// The watermarks are shared with the rollup job, so the test runs in a
+	// transaction on one connection that is rolled back at the end
+	err := db.Connection(func(conn *gorm.DB) error {
+		if err := conn.Exec("BEGIN").Error; err != nil {
+			return err
+		}
+		defer conn.Exec("ROLLBACK")
+
+		// Statements must not open transactions of their own, or they would
+		// commit this one
+		repo := &Repository{db: conn.Session(&gorm.Session{SkipDefaultTransaction: true}), timescale: shared.timescale}
+		rewind := func(at time.Time) error {
+			return conn.Statement.ConnPool.(*sql.Conn).Raw(func(driverConn interface{}) error {
+				return rewindRollupWatermarks(ctx, driverConn.(*stdlib.Conn).Conn(), at)
+			})
+		}
+		if err := repo.db.Exec("DELETE FROM rollup_watermarks").Error; err != nil {
+			return err
+		}
+
+		closed := testHour.Add(2 * time.Hour)
+		if ok, err := repo.AdvanceRollupWatermark(ctx, models.ResolutionHourly, time.Time{}, closed); err != nil || !ok {
+			t.Fatalf("first advance = %v, %v; want true, nil", ok, err)
+		}
+
+		// A snapshot from half past the first hour is saved while the rollup runs
+		if err := rewind(testHour.Add(30 * time.Minute)); err != nil {
+			return err
+		}
+		if watermark, _ := repo.GetRollupWatermark(ctx, models.ResolutionHourly); !watermark.Equal(testHour) {
+			t.Fatalf("watermark after rewind = %s, want %s", watermark, testHour)
+		}
+
+		// The rollup that read the watermark before the rewind must not undo it
+		if ok, err := repo.AdvanceRollupWatermark(ctx, models.ResolutionHourly, closed, closed.Add(time.Hour)); err != nil || ok {
+			t.Fatalf("stale advance = %v, %v; want false, nil", ok, err)
+		}
+		if watermark, _ := repo.GetRollupWatermark(ctx, models.ResolutionHourly); !watermark.Equal(testHour) {
+			t.Errorf("watermark after a stale advance = %s, want %s", watermark, testHour)
+		}
+
+		// Rows in buckets that are still open leave it alone
+		if err := rewind(closed); err != nil {
+			return err
+		}
+		if watermark, _ := repo.GetRollupWatermark(ctx, models.ResolutionHourly); !watermark.Equal(testHour) {
+			t.Errorf("watermark after rows past it = %s, want %s", watermark, testHour)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,155 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"osrs-price-api/internal/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// keyframeSeconds is models.KeyframeInterval in seconds, for SQL interval arithmetic
+var keyframeSeconds = int64(models.KeyframeInterval / time.Second)
+
+// priceHistoryColumns are the price_history columns written by SavePriceSnapshot
+var priceHistoryColumns = []string{
+	"game_mode", "item_id", "high", "high_time", "low", "low_time",
+	"high_volume", "low_volume", "timestamp", "created_at", "snapshot_id",
+}
+
+// SavePriceSnapshot records a successful fetch and stores the prices of the
+// items that changed in it, all in one transaction, so a failed write never
+// leaves a partial snapshot behind
+// Rows are stamped with the snapshot time, linked to the snapshot and streamed
+// with COPY. run is saved along with them; on success the IDs of snapshot and
+// run are set and run is linked to the snapshot. Rollup watermarks past the
+// snapshot time are rewound in the same transaction. A snapshot with no
+// changed items only records the snapshot and run.
+func (r *Repository) SavePriceSnapshot(ctx context.Context, snapshot *models.PriceSnapshot, changed map[string]models.ItemPrice, run *models.FetchRun) error {
+	now := time.Now().UTC()
+
+	var snapshotID, runID int64
+	err := r.withCopyTx(ctx, func(tx pgx.Tx) error {
+		err := tx.QueryRow(ctx, `
+			INSERT INTO price_snapshots (game_mode, snapshot_time, fetched_at, content_hash, items_total, items_changed)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING id
+		`, snapshot.GameMode, snapshot.SnapshotTime, snapshot.FetchedAt, snapshot.ContentHash, snapshot.ItemsTotal, snapshot.ItemsChanged).Scan(&snapshotID)
+		if err != nil {
+			return fmt.Errorf("inserting snapshot: %w", err)
+		}
+
+		if len(changed) > 0 {
+			if err := r.copyPriceRows(ctx, tx, snapshot, changed, snapshotID, now); err != nil {
+				return err
+			}
+		}
+
+		err = tx.QueryRow(ctx, `
+			INSERT INTO fetch_runs (game_mode, source, started_at, finished_at, items, bytes, status, error, snapshot_id)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			RETURNING id
+		`, run.GameMode, run.Source, run.StartedAt, run.FinishedAt, run.Items, run.Bytes, run.Status, run.Error, snapshotID).Scan(&runID)
+		if err != nil {
+			return fmt.Errorf("inserting fetch run: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	snapshot.ID = snapshotID
+	run.ID = runID
+	run.SnapshotID = &snapshot.ID
+	return nil
+}
+
+// copyPriceRows streams the changed prices of a snapshot into price_history
+// and rewinds the rollup watermarks past it
+func (r *Repository) copyPriceRows(ctx context.Context, tx pgx.Tx, snapshot *models.PriceSnapshot, changed map[string]models.ItemPrice, snapshotID int64, now time.Time) error {
+	// A no-op unless the cleanup job fell behind or an old snapshot is replayed
+	if !r.timescale {
+		if _, err := tx.Exec(ctx, "SELECT ensure_price_history_partitions($1, $1)", snapshot.SnapshotTime); err != nil {
+			return fmt.Errorf("creating partition: %w", err)
+		}
+	}
+
+	rows := make([][]interface{}, 0, len(changed))
+	for itemIDStr, price := range changed {
+		itemID, err := strconv.Atoi(itemIDStr)
+		if err != nil {
+			log.Printf("Skipping %s price with invalid item ID %q", snapshot.GameMode, itemIDStr)
+			continue
+		}
+
+		rows = append(rows, []interface{}{
+			snapshot.GameMode, itemID, price.High, price.HighTime, price.Low, price.LowTime,
+			price.HighVolume, price.LowVolume, snapshot.SnapshotTime, now, snapshotID,
+		})
+	}
+	if _, err := copyRows(ctx, tx, "price_history", priceHistoryColumns, rows); err != nil {
+		return err
+	}
+	// A replayed snapshot lands in buckets the rollup may have closed already
+	return rewindRollupWatermarks(ctx, tx, snapshot.SnapshotTime)
+}
+
+// SaveFetchRun records a fetch that didn't produce a snapshot
+func (r *Repository) SaveFetchRun(ctx context.Context, run *models.FetchRun) error {
+	return r.db.WithContext(ctx).Create(run).Error
+}
+
+// GetFetchRuns returns the most recent fetches, newest first
+// An empty mode returns every game mode.
+func (r *Repository) GetFetchRuns(ctx context.Context, mode string, limit int) ([]models.FetchRun, error) {
+	query := r.db.WithContext(ctx).Order("started_at DESC").Limit(limit)
+	if mode != "" {
+		query = query.Where("game_mode = ?", mode)
+	}
+	var runs []models.FetchRun
+	err := query.Find(&runs).Error
+	return runs, err
+}
+
+// DeleteOldFetchRuns deletes fetch records older than the given date
+func (r *Repository) DeleteOldFetchRuns(ctx context.Context, cutoffDate time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Where("started_at < ?", cutoffDate).Delete(&models.FetchRun{})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	return result.RowsAffected, nil
+}
+
+// GetLastPriceSnapshot returns the most recent fetch for a game mode, or nil
+// if nothing has been fetched yet
+func (r *Repository) GetLastPriceSnapshot(ctx context.Context, mode string) (*models.PriceSnapshot, error) {
+	var snapshots []models.PriceSnapshot
+	err := r.db.WithContext(ctx).Where("game_mode = ?", mode).
+		Order("snapshot_time DESC, id DESC").
+		Limit(1).
+		Find(&snapshots).Error
+	if err != nil || len(snapshots) == 0 {
+		return nil, err
+	}
+	return &snapshots[0], nil
+}
+
+// GetLatestStoredPrices returns the newest row of every item in a game mode
+// Items are only stored when they change, so this looks back up to
+// models.KeyframeInterval from the newest row rather than at a single timestamp.
+func (r *Repository) GetLatestStoredPrices(ctx context.Context, mode string) ([]models.PriceHistory, error) {
+	var records []models.PriceHistory
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT DISTINCT ON (item_id) *
+		FROM price_history
+		WHERE game_mode = ?
+			AND timestamp > (SELECT MAX(timestamp) FROM price_history WHERE game_mode = ?) - ? * INTERVAL '1 second'
+		ORDER BY item_id, timestamp DESC, id DESC
+	`, mode, mode, keyframeSeconds).Scan(&records).Error
+	return records, err
+}
@@ -0,0 +1,41 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"osrs-price-api/internal/models"
+)
+
+func TestSavePriceSnapshotWithoutChanges(t *testing.T) {
+	repo, db := newTestRepository(t)
+	ctx := context.Background()
+
+	snapshot := &models.PriceSnapshot{
+		GameMode:     models.GameModeMain,
+		SnapshotTime: testHour,
+		FetchedAt:    testHour,
+		ContentHash:  "unchanged",
+		ItemsTotal:   2,
+	}
+	run := &models.FetchRun{GameMode: models.GameModeMain, Source: "fixture", StartedAt: testHour, FinishedAt: testHour, Status: models.FetchStatusSuccess}
+	t.Cleanup(func() {
+		db.Exec("DELETE FROM fetch_runs WHERE id = ?", run.ID)
+		db.Exec("DELETE FROM price_snapshots WHERE id = ?", snapshot.ID)
+	})
+
+	if err := repo.SavePriceSnapshot(ctx, snapshot, map[string]models.ItemPrice{}, run); err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.ID == 0 || run.ID == 0 || run.SnapshotID == nil || *run.SnapshotID != snapshot.ID {
+		t.Fatalf("snapshot %d and run %+v weren't recorded together", snapshot.ID, run)
+	}
+
+	var rows int64
+	if err := db.Raw("SELECT COUNT(*) FROM price_history WHERE snapshot_id = ?", snapshot.ID).Scan(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+	if rows != 0 {
+		t.Errorf("unchanged snapshot stored %d price rows, want 0", rows)
+	}
+}
@@ -0,0 +1,299 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Storage backends, selected with DB_STORAGE
+const (
+	StoragePostgres  = "postgres"    // Plain PostgreSQL: partitioned raw table, rollups written by the rollup job
+	StorageTimescale = "timescaledb" // TimescaleDB: hypertables and a continuous aggregate
+)
+
+// With TimescaleDB, price_history is a hypertable with daily chunks, the
+// hourly tier a hypertable with weekly chunks and the daily tier a continuous
+// aggregate over it, price_history_daily_cagg. price_history_hourly and
+// price_history_daily become views with the columns of the original tables,
+// which are kept as *_base for the buckets aggregated before the switch;
+// where both have a bucket, the new one wins. Everything reading the tiers
+// works unchanged.
+//
+// This deliberately stops short of replacing every rollup and the cleanup job
+// with TimescaleDB's own machinery:
+//   - The hourly tier is not a continuous aggregate. Raw prices are only
+//     stored when they change, and the hourly tier is aggregated over a
+//     carry-forward 5-minute grid (see gridQuery); a continuous aggregate can
+//     only group the stored rows, so it would skip every hour an item didn't
+//     change in. AggregateToHourly writes it as with plain PostgreSQL.
+//   - There are no retention policies. Retention is set per tier in
+//     cleanup_config and can change at runtime, and raw data and hours must
+//     not be dropped before they have been rolled up, which a fixed
+//     drop_after can't know. The cleanup job drops whole chunks with
+//     drop_chunks instead of deleting rows, up to the same cutoffs.
+// The rollup job still decides when a bucket is closed and refreshes the
+// daily aggregate up to its watermark. Compression is left to TimescaleDB's
+// policies.
+
+// Chunks older than these are compressed by TimescaleDB's own policies
+// They stay clear of the ranges the rollup job refreshes on schedule, but late
+// rows (spool replays, backfills and the buckets rewound for them) can still
+// land in compressed chunks, which needs minTimescaleVersion.
+const (
+	compressRawAfter    = 2 * 24 * time.Hour
+	compressHourlyAfter = 30 * 24 * time.Hour
+	compressDailyAfter  = 365 * 24 * time.Hour
+)
+
+// SetupStorage checks that the database is set up for a storage backend after
+// migrations have run
+// Converting to TimescaleDB is a separate step, ConvertToTimescale, since it
+// copies the whole raw table.
+func SetupStorage(ctx context.Context, db *gorm.DB, storage string) error {
+	switch storage {
+	case StoragePostgres, StorageTimescale:
+	default:
+		return fmt.Errorf("unknown DB_STORAGE %q (expected %s or %s)", storage, StoragePostgres, StorageTimescale)
+	}
+
+	converted, err := timescaleConverted(db.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	switch {
+	case storage == StoragePostgres && converted:
+		return fmt.Errorf("database was set up for DB_STORAGE=%s", StorageTimescale)
+	case storage == StorageTimescale && !converted:
+		return fmt.Errorf("database isn't converted for DB_STORAGE=%s yet; run the migrate tool with -command=timescale first", StorageTimescale)
+	case storage == StorageTimescale:
+		return checkTimescaleVersion(db.WithContext(ctx))
+	}
+	return nil
+}
+
+// minTimescaleVersion is the oldest TimescaleDB release that can update and
+// upsert into compressed chunks
+var minTimescaleVersion = [2]int{2, 11}
+
+// checkTimescaleVersion fails unless the installed TimescaleDB is at least
+// minTimescaleVersion
+func checkTimescaleVersion(db *gorm.DB) error {
+	var version string
+	if err := db.Raw("SELECT extversion FROM pg_extension WHERE extname = 'timescaledb'").Scan(&version).Error; err != nil {
+		return err
+	}
+	if version == "" {
+		return fmt.Errorf("the timescaledb extension isn't installed")
+	}
+	if !versionAtLeast(version, minTimescaleVersion) {
+		return fmt.Errorf("TimescaleDB %s is too old for DB_STORAGE=%s: writing late rows into compressed chunks needs %d.%d or later",
+			version, StorageTimescale, minTimescaleVersion[0], minTimescaleVersion[1])
+	}
+	return nil
+}
+
+// versionAtLeast reports whether a major.minor[.patch] version is at least min
+func versionAtLeast(version string, min [2]int) bool {
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 2 {
+		return false
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return false
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return false
+	}
+	return major > min[0] || major == min[0] && minor >= min[1]
+}
+
+// timescaleConverted reports whether ConvertToTimescale has finished
+func timescaleConverted(db *gorm.DB) (bool, error) {
+	var converted bool
+	err := db.Raw("SELECT to_regclass('price_history_daily_cagg') IS NOT NULL").Scan(&converted).Error
+	return converted, err
+}
+
+// addCompressionPolicies has TimescaleDB compress the chunks of the raw table
+// and the tiers once they are old enough
+func addCompressionPolicies(tx *gorm.DB) error {
+	for relation, after := range map[string]time.Duration{
+		"price_history":                   compressRawAfter,
+		"price_history_hourly_hypertable": compressHourlyAfter,
+		"price_history_daily_cagg":        compressDailyAfter,
+	} {
+		err := tx.Exec("SELECT add_compression_policy(?::regclass, compress_after => ? * INTERVAL '1 second', if_not_exists => true)", relation, int64(after/time.Second)).Error
+		if err != nil {
+			return fmt.Errorf("adding compression policy for %s: %w", relation, err)
+		}
+	}
+	return nil
+}
+
+func execAll(tx *gorm.DB, statements []string) error {
+	for _, statement := range statements {
+		if err := tx.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// aggregateStatements move the hourly tier to a hypertable written by
+// AggregateToHourly and replace the daily table with a continuous aggregate
+// computing the same values as AggregateToDaily, behind views with the
+// original columns
+// The hours of the days the daily tier hasn't closed yet are copied over, so
+// the aggregate sees whole days.
+var aggregateStatements = []string{
+	`ALTER TABLE price_history_hourly RENAME TO price_history_hourly_base`,
+	`ALTER TABLE price_history_daily RENAME TO price_history_daily_base`,
+
+	`CREATE TABLE price_history_hourly_hypertable (LIKE price_history_hourly_base INCLUDING DEFAULTS INCLUDING COMMENTS)`,
+	`SELECT create_hypertable('price_history_hourly_hypertable', 'hour_timestamp', chunk_time_interval => INTERVAL '7 days')`,
+	`ALTER TABLE price_history_hourly_hypertable ADD PRIMARY KEY (id, hour_timestamp)`,
+	`CREATE UNIQUE INDEX idx_hourly_hypertable_item_time ON price_history_hourly_hypertable (game_mode, item_id, hour_timestamp)`,
+	`ALTER TABLE price_history_hourly_hypertable SET (
+		timescaledb.compress,
+		timescaledb.compress_segmentby = 'game_mode, item_id',
+		timescaledb.compress_orderby = 'hour_timestamp DESC'
+	)`,
+	`INSERT INTO price_history_hourly_hypertable
+	SELECT * FROM price_history_hourly_base
+	WHERE hour_timestamp >= COALESCE(
+		(SELECT watermark AT TIME ZONE 'UTC' FROM rollup_watermarks WHERE tier = 'daily'),
+		'-infinity'
+	)`,
+
+	`CREATE MATERIALIZED VIEW price_history_daily_cagg
+	WITH (timescaledb.continuous, timescaledb.materialized_only = true) AS
+	SELECT
+		game_mode,
+		item_id,
+		time_bucket(INTERVAL '1 day', hour_timestamp) AS day_bucket,
+		AVG(avg_high)::bigint AS avg_high,
+		AVG(avg_low)::bigint AS avg_low,
+		MAX(max_high) AS max_high,
+		MIN(min_high) AS min_high,
+		MAX(max_low) AS max_low,
+		MIN(min_low) AS min_low,
+		first(opening_high, hour_timestamp) AS opening_high,
+		first(opening_low, hour_timestamp) AS opening_low,
+		last(closing_high, hour_timestamp) AS closing_high,
+		last(closing_low, hour_timestamp) AS closing_low,
+		SUM(total_high_volume)::bigint AS total_high_volume,
+		SUM(total_low_volume)::bigint AS total_low_volume,
+		CASE WHEN AVG(avg_high) > 0 THEN (MAX(max_high) - MIN(min_low))::float / AVG(avg_high) ELSE 0 END AS volatility,
+		SUM(data_points)::integer AS data_points
+	FROM price_history_hourly_hypertable
+	GROUP BY game_mode, item_id, time_bucket(INTERVAL '1 day', hour_timestamp)
+	WITH NO DATA`,
+	`CREATE INDEX idx_daily_cagg_item_date ON price_history_daily_cagg (game_mode, item_id, day_bucket)`,
+	`ALTER MATERIALIZED VIEW price_history_daily_cagg SET (timescaledb.compress = true)`,
+
+	`CREATE VIEW price_history_hourly AS
+	SELECT id, game_mode, item_id, avg_high, avg_low, max_high, min_high, max_low, min_low,
+		opening_high, opening_low, closing_high, closing_low, total_high_volume, total_low_volume, data_points,
+		hour_timestamp, created_at
+	FROM price_history_hourly_hypertable
+	UNION ALL
+	SELECT id, game_mode, item_id, avg_high, avg_low, max_high, min_high, max_low, min_low,
+		opening_high, opening_low, closing_high, closing_low, total_high_volume, total_low_volume, data_points,
+		hour_timestamp, created_at
+	FROM price_history_hourly_base b
+	WHERE NOT EXISTS (
+		SELECT 1 FROM price_history_hourly_hypertable h
+		WHERE h.game_mode = b.game_mode AND h.item_id = b.item_id AND h.hour_timestamp = b.hour_timestamp
+	)`,
+
+	`CREATE VIEW price_history_daily AS
+	SELECT NULL::bigint AS id, game_mode, item_id, avg_high, avg_low, max_high, min_high, max_low, min_low,
+		opening_high, opening_low, closing_high, closing_low, total_high_volume, total_low_volume, volatility, data_points,
+		day_bucket::date AS day_date, NULL::timestamp AS created_at
+	FROM price_history_daily_cagg
+	UNION ALL
+	SELECT id, game_mode, item_id, avg_high, avg_low, max_high, min_high, max_low, min_low,
+		opening_high, opening_low, closing_high, closing_low, total_high_volume, total_low_volume, volatility, data_points,
+		day_date, created_at
+	FROM price_history_daily_base b
+	WHERE NOT EXISTS (
+		SELECT 1 FROM price_history_daily_cagg c
+		WHERE c.game_mode = b.game_mode AND c.item_id = b.item_id AND c.day_bucket = b.day_date::timestamp
+	)`,
+}
+
+// aggregateTables are the tables aggregates of a tier are written to with
+// TimescaleDB
+var aggregateTables = map[string]string{
+	"price_history_hourly": "price_history_hourly_hypertable",
+	"price_history_daily":  "price_history_daily_base",
+}
+
+// aggregateTable returns the table aggregates of a tier are written to: the
+// tier's own table, or with TimescaleDB the table behind its view
+func (r *Repository) aggregateTable(table string) string {
+	if r.timescale {
+		return aggregateTables[table]
+	}
+	return table
+}
+
+// deleteOldAggregates drops the expired chunks of the hypertable or continuous
+// aggregate behind a tier's view and deletes the expired rows of its base
+// table
+func (r *Repository) deleteOldAggregates(ctx context.Context, relation, column, base, baseColumn string, cutoffDate time.Time) (int64, error) {
+	dropped, _, err := r.dropChunks(ctx, relation, column, "timestamp", cutoffDate)
+	if err != nil {
+		return 0, err
+	}
+	deleted, err := r.deleteBefore(ctx, base, baseColumn, cutoffDate)
+	return dropped + deleted, err
+}
+
+// refreshAggregate materializes the buckets of a continuous aggregate over
+// TIMESTAMP buckets in [startTime, endTime) and returns how many there are
+// Refreshing can't run inside a transaction, so it goes straight to the pool.
+func (r *Repository) refreshAggregate(ctx context.Context, cagg, column string, startTime, endTime time.Time) (int64, error) {
+	err := r.db.WithContext(ctx).Exec(fmt.Sprintf("CALL refresh_continuous_aggregate('%s', ?::timestamp, ?::timestamp)", cagg), startTime, endTime).Error
+	if err != nil {
+		return 0, fmt.Errorf("refreshing %s: %w", cagg, err)
+	}
+
+	var buckets int64
+	err = r.db.WithContext(ctx).Raw(fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s >= ? AND %s < ?", cagg, column, column), startTime, endTime).Scan(&buckets).Error
+	return buckets, err
+}
+
+// dropChunks drops the chunks of a hypertable or continuous aggregate holding
+// only rows older than cutoff, returning how many rows they held and where the
+// newest of them ended (zero if none was dropped)
+// timeType is the SQL type of its time column, which cutoff is passed as.
+func (r *Repository) dropChunks(ctx context.Context, relation, column, timeType string, cutoff time.Time) (int64, time.Time, error) {
+	var droppedTo *time.Time
+	err := r.db.WithContext(ctx).Raw(fmt.Sprintf(`
+		SELECT MAX(c.range_end)
+		FROM timescaledb_information.chunks c
+		JOIN show_chunks(?::regclass, older_than => ?::%s) s
+			ON s = format('%%I.%%I', c.chunk_schema, c.chunk_name)::regclass
+	`, timeType), relation, cutoff).Scan(&droppedTo).Error
+	if err != nil || droppedTo == nil {
+		return 0, time.Time{}, err
+	}
+
+	var rows int64
+	err = r.db.WithContext(ctx).Raw(fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s < ?", relation, column), *droppedTo).Scan(&rows).Error
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	if err := r.db.WithContext(ctx).Exec(fmt.Sprintf("SELECT drop_chunks(?::regclass, older_than => ?::%s)", timeType), relation, cutoff).Error; err != nil {
+		return 0, time.Time{}, fmt.Errorf("dropping chunks of %s: %w", relation, err)
+	}
+	return rows, droppedTo.UTC(), nil
+}
@@ -0,0 +1,188 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Converting to TimescaleDB copies price_history into a hypertable while the
+// servers keep running with DB_STORAGE=postgres:
+//  1. price_history_hypertable is created with the keys and indexes of
+//     price_history, and a trigger copies every row written to price_history
+//     from then on
+//  2. the rows stored before that are copied a day at a time, each day in its
+//     own transaction, with the progress kept in timescale_conversion so an
+//     interrupted conversion picks up where it stopped
+//  3. one short transaction swaps the tables, converts the hourly and daily
+//     tiers and adds the compression policies
+// Servers have to be restarted with DB_STORAGE=timescaledb afterwards; until
+// then snapshots that fail to save are spooled as usual.
+
+// conversionBatch is how much of price_history is copied per transaction
+const conversionBatch = 24 * time.Hour
+
+// conversionLockID is the Postgres advisory lock key held while converting,
+// so only one conversion runs at a time; migrations (7_245_301) only wait for
+// the swap, and the leader lock (7_245_302) is left to the running servers
+const conversionLockID = 7_245_303
+
+// ConvertToTimescale converts a database set up for StoragePostgres for
+// StorageTimescale; it is idempotent and can be run again to resume
+func ConvertToTimescale(ctx context.Context, db *gorm.DB) error {
+	if err := db.WithContext(ctx).Exec("CREATE EXTENSION IF NOT EXISTS timescaledb").Error; err != nil {
+		return fmt.Errorf("enabling timescaledb (is it in shared_preload_libraries?): %w", err)
+	}
+	if err := checkTimescaleVersion(db.WithContext(ctx)); err != nil {
+		return err
+	}
+
+	// A session lock needs every statement on the same connection
+	return db.WithContext(ctx).Connection(func(conn *gorm.DB) error {
+		if err := conn.Exec("SELECT pg_advisory_lock(?)", conversionLockID).Error; err != nil {
+			return fmt.Errorf("failed to acquire conversion lock: %w", err)
+		}
+		defer conn.WithContext(context.Background()).Exec("SELECT pg_advisory_unlock(?)", conversionLockID)
+
+		converted, err := timescaleConverted(conn)
+		if err != nil {
+			return err
+		}
+		if converted {
+			log.Println("Database is already converted to TimescaleDB")
+			return nil
+		}
+
+		var started bool
+		if err := conn.Raw("SELECT to_regclass('timescale_conversion') IS NOT NULL").Scan(&started).Error; err != nil {
+			return err
+		}
+		if !started {
+			log.Println("Creating the price_history hypertable...")
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				return execAll(tx, conversionStartStatements)
+			})
+			if err != nil {
+				return fmt.Errorf("creating the price_history hypertable: %w", err)
+			}
+		}
+
+		if err := copyPriceHistory(conn); err != nil {
+			return err
+		}
+
+		log.Println("Swapping in the hypertable and converting the hourly and daily tiers...")
+		return conn.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", migrationLockID).Error; err != nil {
+				return err
+			}
+			if err := execAll(tx, swapStatements); err != nil {
+				return fmt.Errorf("swapping in the hypertable: %w", err)
+			}
+			if err := execAll(tx, aggregateStatements); err != nil {
+				return fmt.Errorf("converting the hourly and daily tiers: %w", err)
+			}
+			return addCompressionPolicies(tx)
+		})
+	})
+}
+
+// copyPriceHistory copies the rows price_history held when the conversion
+// started into the hypertable, a batch per transaction, oldest first
+func copyPriceHistory(db *gorm.DB) error {
+	var progress struct {
+		CopiedTo *time.Time
+		CopyEnd  *time.Time
+	}
+	if err := db.Raw("SELECT copied_to, copy_end FROM timescale_conversion").Scan(&progress).Error; err != nil {
+		return err
+	}
+	if progress.CopiedTo == nil {
+		return nil // price_history was empty
+	}
+
+	end := progress.CopyEnd.UTC()
+	for from := progress.CopiedTo.UTC(); from.Before(end); {
+		to := from.Truncate(conversionBatch).Add(conversionBatch)
+		if to.After(end) {
+			to = end
+		}
+
+		var copied int64
+		err := db.Transaction(func(tx *gorm.DB) error {
+			// Rows written since the start were copied by the trigger already
+			result := tx.Exec(`
+				INSERT INTO price_history_hypertable
+				SELECT * FROM price_history
+				WHERE timestamp >= ? AND timestamp < ?
+				ON CONFLICT DO NOTHING
+			`, from, to)
+			if result.Error != nil {
+				return result.Error
+			}
+			copied = result.RowsAffected
+			return tx.Exec("UPDATE timescale_conversion SET copied_to = ?", to).Error
+		})
+		if err != nil {
+			return fmt.Errorf("copying price_history from %s: %w", from.Format(time.RFC3339), err)
+		}
+		log.Printf("Copied %d price_history rows up to %s", copied, to.Format(time.RFC3339))
+		from = to
+	}
+	return nil
+}
+
+// conversionStartStatements create the hypertable price_history is copied
+// into, with the same columns, keys and indexes, and the trigger that copies
+// every row written from then on
+// The range left to copy is taken after creating the trigger, which waits for
+// the writes in flight, so every row is either in it or copied by the trigger.
+var conversionStartStatements = []string{
+	`CREATE TABLE price_history_hypertable (LIKE price_history INCLUDING DEFAULTS INCLUDING COMMENTS)`,
+	`SELECT create_hypertable('price_history_hypertable', 'timestamp', chunk_time_interval => INTERVAL '1 day')`,
+	`ALTER TABLE price_history_hypertable ADD PRIMARY KEY (id, timestamp)`,
+	`ALTER TABLE price_history_hypertable ADD CONSTRAINT price_history_snapshot_id_fkey
+		FOREIGN KEY (snapshot_id) REFERENCES price_snapshots (id) ON DELETE SET NULL`,
+	`CREATE INDEX idx_hypertable_item_timestamp ON price_history_hypertable (game_mode, item_id, timestamp)`,
+	`CREATE INDEX idx_hypertable_volume ON price_history_hypertable (item_id, timestamp, high_volume, low_volume)`,
+	`CREATE INDEX idx_hypertable_snapshot ON price_history_hypertable (snapshot_id)`,
+	`ALTER TABLE price_history_hypertable SET (
+		timescaledb.compress,
+		timescaledb.compress_segmentby = 'game_mode, item_id',
+		timescaledb.compress_orderby = 'timestamp DESC, id DESC'
+	)`,
+
+	`CREATE FUNCTION copy_to_price_history_hypertable() RETURNS trigger AS $$
+	BEGIN
+		INSERT INTO price_history_hypertable VALUES (NEW.*) ON CONFLICT DO NOTHING;
+		RETURN NULL;
+	END;
+	$$ LANGUAGE plpgsql`,
+	`CREATE TRIGGER price_history_to_hypertable AFTER INSERT ON price_history
+		FOR EACH ROW EXECUTE FUNCTION copy_to_price_history_hypertable()`,
+
+	`CREATE TABLE timescale_conversion AS
+	SELECT MIN(timestamp) AS copied_to, MAX(timestamp) + INTERVAL '1 microsecond' AS copy_end
+	FROM price_history`,
+}
+
+// swapStatements replace price_history with the hypertable once every row
+// has been copied, giving it the names of the original keys and indexes
+var swapStatements = []string{
+	`LOCK TABLE price_history IN ACCESS EXCLUSIVE MODE`,
+	`ALTER SEQUENCE price_history_id_seq OWNED BY NONE`,
+	`DROP TABLE price_history`,
+	`DROP FUNCTION copy_to_price_history_hypertable()`,
+	`DROP TABLE timescale_conversion`,
+
+	`ALTER TABLE price_history_hypertable RENAME TO price_history`,
+	`ALTER SEQUENCE price_history_id_seq OWNED BY price_history.id`,
+	`ALTER TABLE price_history RENAME CONSTRAINT price_history_hypertable_pkey TO price_history_pkey`,
+	`ALTER INDEX price_history_hypertable_timestamp_idx RENAME TO idx_timestamp`,
+	`ALTER INDEX idx_hypertable_item_timestamp RENAME TO idx_item_timestamp`,
+	`ALTER INDEX idx_hypertable_volume RENAME TO idx_price_history_volume`,
+	`ALTER INDEX idx_hypertable_snapshot RENAME TO idx_price_history_snapshot`,
+}
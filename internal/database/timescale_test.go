@@ -0,0 +1,22 @@
+package database
+
+import "testing"
+
+func TestVersionAtLeast(t *testing.T) {
+	for version, want := range map[string]bool{
+		"2.11.0":     true,
+		"2.11":       true,
+		"2.17.2":     true,
+		"3.0.0":      true,
+		"2.10.3":     false,
+		"2.9.0":      false,
+		"1.7.5":      false,
+		"2":          false,
+		"2.x":        false,
+		"2.14.0-dev": true,
+	} {
+		if got := versionAtLeast(version, [2]int{2, 11}); got != want {
+			t.Errorf("versionAtLeast(%q, 2.11) = %v, want %v", version, got, want)
+		}
+	}
+}
@@ -5,10 +5,13 @@ import "time"
 // PriceHistoryHourly stores hourly aggregated price data
 type PriceHistoryHourly struct {
 	ID              uint      `gorm:"primaryKey" json:"id"`
-	ItemID          int       `gorm:"index:idx_hourly_item_time;not null" json:"item_id"`
+	GameMode        string    `gorm:"type:varchar(8);default:main;not null;uniqueIndex:idx_hourly_item_time,priority:1" json:"game_mode"`
+	ItemID          int       `gorm:"uniqueIndex:idx_hourly_item_time;not null" json:"item_id"`
 	AvgHigh         int64     `json:"avg_high"`
 	AvgLow          int64     `json:"avg_low"`
 	MaxHigh         int64     `json:"max_high"`
+	MinHigh         int64     `json:"min_high"`
+	MaxLow          int64     `json:"max_low"`
 	MinLow          int64     `json:"min_low"`
 	OpeningHigh     int64     `json:"opening_high"`
 	OpeningLow      int64     `json:"opening_low"`
@@ -17,7 +20,7 @@ type PriceHistoryHourly struct {
 	TotalHighVolume int64     `json:"total_high_volume"`
 	TotalLowVolume  int64     `json:"total_low_volume"`
 	DataPoints      int       `json:"data_points"`
-	HourTimestamp   time.Time `gorm:"index:idx_hourly_item_time;not null" json:"hour_timestamp"`
+	HourTimestamp   time.Time `gorm:"uniqueIndex:idx_hourly_item_time;not null" json:"hour_timestamp"`
 	CreatedAt       time.Time `json:"created_at"`
 }
 
@@ -29,10 +32,13 @@ func (PriceHistoryHourly) TableName() string {
 // PriceHistoryDaily stores daily aggregated price data
 type PriceHistoryDaily struct {
 	ID              uint      `gorm:"primaryKey" json:"id"`
-	ItemID          int       `gorm:"index:idx_daily_item_date;not null" json:"item_id"`
+	GameMode        string    `gorm:"type:varchar(8);default:main;not null;uniqueIndex:idx_daily_item_date,priority:1" json:"game_mode"`
+	ItemID          int       `gorm:"uniqueIndex:idx_daily_item_date;not null" json:"item_id"`
 	AvgHigh         int64     `json:"avg_high"`
 	AvgLow          int64     `json:"avg_low"`
 	MaxHigh         int64     `json:"max_high"`
+	MinHigh         int64     `json:"min_high"`
+	MaxLow          int64     `json:"max_low"`
 	MinLow          int64     `json:"min_low"`
 	OpeningHigh     int64     `json:"opening_high"`
 	OpeningLow      int64     `json:"opening_low"`
@@ -49,4 +55,60 @@ type PriceHistoryDaily struct {
 // TableName specifies the table name for GORM
 func (PriceHistoryDaily) TableName() string {
 	return "price_history_daily"
-}
\ No newline at end of file
+}
+
+// PriceHistoryWeekly stores weekly aggregated price data, rolled up from the daily tier
+type PriceHistoryWeekly struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	GameMode        string    `gorm:"type:varchar(8);default:main;not null;uniqueIndex:idx_weekly_item_date,priority:1" json:"game_mode"`
+	ItemID          int       `gorm:"uniqueIndex:idx_weekly_item_date;not null" json:"item_id"`
+	AvgHigh         int64     `json:"avg_high"`
+	AvgLow          int64     `json:"avg_low"`
+	MaxHigh         int64     `json:"max_high"`
+	MinHigh         int64     `json:"min_high"`
+	MaxLow          int64     `json:"max_low"`
+	MinLow          int64     `json:"min_low"`
+	OpeningHigh     int64     `json:"opening_high"`
+	OpeningLow      int64     `json:"opening_low"`
+	ClosingHigh     int64     `json:"closing_high"`
+	ClosingLow      int64     `json:"closing_low"`
+	TotalHighVolume int64     `json:"total_high_volume"`
+	TotalLowVolume  int64     `json:"total_low_volume"`
+	Volatility      float64   `json:"volatility"`
+	DataPoints      int       `json:"data_points"`
+	WeekStart       time.Time `gorm:"type:date;uniqueIndex:idx_weekly_item_date;not null" json:"week_start"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// TableName specifies the table name for GORM
+func (PriceHistoryWeekly) TableName() string {
+	return "price_history_weekly"
+}
+
+// PriceHistoryMonthly stores monthly aggregated price data, rolled up from the daily tier
+type PriceHistoryMonthly struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	GameMode        string    `gorm:"type:varchar(8);default:main;not null;uniqueIndex:idx_monthly_item_date,priority:1" json:"game_mode"`
+	ItemID          int       `gorm:"uniqueIndex:idx_monthly_item_date;not null" json:"item_id"`
+	AvgHigh         int64     `json:"avg_high"`
+	AvgLow          int64     `json:"avg_low"`
+	MaxHigh         int64     `json:"max_high"`
+	MinHigh         int64     `json:"min_high"`
+	MaxLow          int64     `json:"max_low"`
+	MinLow          int64     `json:"min_low"`
+	OpeningHigh     int64     `json:"opening_high"`
+	OpeningLow      int64     `json:"opening_low"`
+	ClosingHigh     int64     `json:"closing_high"`
+	ClosingLow      int64     `json:"closing_low"`
+	TotalHighVolume int64     `json:"total_high_volume"`
+	TotalLowVolume  int64     `json:"total_low_volume"`
+	Volatility      float64   `json:"volatility"`
+	DataPoints      int       `json:"data_points"`
+	MonthStart      time.Time `gorm:"type:date;uniqueIndex:idx_monthly_item_date;not null" json:"month_start"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// TableName specifies the table name for GORM
+func (PriceHistoryMonthly) TableName() string {
+	return "price_history_monthly"
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// CandleIntervals maps each supported candle interval to its bucket size
+// Weekly candles start on Monday 00:00 UTC; all others are aligned to the Unix epoch.
+var CandleIntervals = map[string]time.Duration{
+	"5m":  5 * time.Minute,
+	"15m": 15 * time.Minute,
+	"1h":  time.Hour,
+	"4h":  4 * time.Hour,
+	"1d":  24 * time.Hour,
+	"1w":  7 * 24 * time.Hour,
+}
+
+// OHLC is one side of a candle: open, high, low and close prices in GP
+// plus the number of items traded on that side
+type OHLC struct {
+	Open   int64 `json:"open"`
+	High   int64 `json:"high"`
+	Low    int64 `json:"low"`
+	Close  int64 `json:"close"`
+	Volume int64 `json:"volume"`
+}
+
+// Candle is an OHLC bucket for both sides of the Grand Exchange
+// High is the instant-buy price series and Low the instant-sell price series.
+type Candle struct {
+	Timestamp  time.Time `json:"timestamp"` // Start of the bucket
+	High       OHLC      `json:"high"`
+	Low        OHLC      `json:"low"`
+	Resolution string    `json:"resolution"` // Tier the bucket was computed from
+}
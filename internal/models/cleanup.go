@@ -0,0 +1,90 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// CleanupConfig is the retention setting for one storage tier
+// Changes take effect on the cleanup worker's next run.
+type CleanupConfig struct {
+	ID                    uint       `gorm:"primaryKey" json:"id"`
+	Tier                  string     `gorm:"type:varchar(16);uniqueIndex:idx_cleanup_config_tier;not null" json:"tier"`
+	RetentionDays         int        `gorm:"not null" json:"retention_days"` // 0 keeps the tier forever
+	Enabled               bool       `json:"enabled"`
+	LastCleanup           *time.Time `json:"last_cleanup"`
+	RecordsDeletedLastRun int64      `json:"records_deleted_last_run"`
+	CreatedAt             time.Time  `json:"created_at"`
+	UpdatedAt             time.Time  `json:"updated_at"`
+}
+
+// TableName specifies the table name for GORM
+func (CleanupConfig) TableName() string {
+	return "cleanup_config"
+}
+
+// Jobs and statuses recorded in cleanup_logs
+const (
+	CleanupJobCleanup = "cleanup"
+	CleanupJobRollup  = "rollup"
+
+	CleanupStatusSuccess = "success"
+	CleanupStatusFailed  = "failed"
+)
+
+// CleanupLog records one cleanup or rollup run
+type CleanupLog struct {
+	ID                uint               `gorm:"primaryKey" json:"id"`
+	Job               string             `gorm:"type:varchar(16);not null" json:"job"`
+	RecordsAggregated int64              `json:"records_aggregated"`
+	RecordsDeleted    int64              `gorm:"not null" json:"records_deleted"`
+	CutoffDate        *time.Time         `json:"cutoff_date,omitempty"`
+	DurationMs        int                `gorm:"not null" json:"duration_ms"`
+	Status            string             `gorm:"type:varchar(50);not null" json:"status"`
+	ErrorMessage      *string            `json:"error_message,omitempty"`
+	Tiers             CleanupTierResults `gorm:"type:jsonb" json:"tiers"`
+	CreatedAt         time.Time          `json:"created_at"`
+}
+
+// TableName specifies the table name for GORM
+func (CleanupLog) TableName() string {
+	return "cleanup_logs"
+}
+
+// CleanupTierResult is what one run did to one tier
+type CleanupTierResult struct {
+	Tier       string     `json:"tier"`
+	Aggregated int64      `json:"aggregated,omitempty"`
+	Deleted    int64      `json:"deleted,omitempty"`
+	Cutoff     *time.Time `json:"cutoff,omitempty"` // Deletion cutoff after clamping to rollup watermarks
+	Error      string     `json:"error,omitempty"`
+}
+
+// CleanupTierResults is stored as a JSONB array
+type CleanupTierResults []CleanupTierResult
+
+// Value implements driver.Valuer
+func (r CleanupTierResults) Value() (driver.Value, error) {
+	if r == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(r)
+	return string(b), err
+}
+
+// Scan implements sql.Scanner
+func (r *CleanupTierResults) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*r = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, r)
+	case string:
+		return json.Unmarshal([]byte(v), r)
+	default:
+		return fmt.Errorf("cannot scan %T into CleanupTierResults", value)
+	}
+}
@@ -0,0 +1,21 @@
+package models
+
+// Game modes with separate Grand Exchange markets tracked by the OSRS Wiki
+const (
+	GameModeMain       = "main" // Main game worlds
+	GameModeDeadman    = "dmm"  // Deadman Mode worlds
+	GameModeFreshStart = "fsw"  // Fresh Start worlds
+)
+
+// GameModes lists every supported game mode
+var GameModes = []string{GameModeMain, GameModeDeadman, GameModeFreshStart}
+
+// IsValidGameMode reports whether mode is a supported game mode
+func IsValidGameMode(mode string) bool {
+	for _, m := range GameModes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
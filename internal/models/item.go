@@ -1,5 +1,10 @@
 package models
 
+import (
+	"strconv"
+	"time"
+)
+
 // ItemPrice represents the price information for an OSRS item
 type ItemPrice struct {
 	ID         int    `json:"id"`
@@ -27,8 +32,65 @@ type ItemPriceData struct {
 	LowVolume  int64 `json:"lowPriceVolume"`  // Volume from OSRS Wiki API
 }
 
-// ItemMapping represents item ID to name mapping
+// ItemMapping represents an entry in the item catalog from the OSRS Wiki /mapping endpoint
 type ItemMapping struct {
-	ID   int    `json:"id"`
-	Name string `json:"name"`
-}
\ No newline at end of file
+	ID        int       `gorm:"primaryKey;autoIncrement:false" json:"id"`
+	Name      string    `gorm:"index:idx_items_name;not null" json:"name"`
+	Examine   string    `json:"examine"`
+	Members   bool      `json:"members"`
+	BuyLimit  int       `json:"limit,omitempty"`    // GE buy limit per 4 hours
+	LowAlch   int64     `json:"lowalch,omitempty"`  // Low Alchemy value
+	HighAlch  int64     `json:"highalch,omitempty"` // High Alchemy value
+	Value     int64     `json:"value"`              // Store value
+	Icon      string    `json:"icon"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for GORM
+func (ItemMapping) TableName() string {
+	return "items"
+}
+
+// AveragePriceResponse represents the response from the OSRS Wiki /5m and /1h endpoints
+type AveragePriceResponse struct {
+	Data      map[string]AveragePriceData `json:"data"`
+	Timestamp int64                       `json:"timestamp"` // Unix start of the averaging window
+}
+
+// AveragePriceData represents the raw average price data for one item in a window
+type AveragePriceData struct {
+	AvgHighPrice    int64 `json:"avgHighPrice"`
+	HighPriceVolume int64 `json:"highPriceVolume"`
+	AvgLowPrice     int64 `json:"avgLowPrice"`
+	LowPriceVolume  int64 `json:"lowPriceVolume"`
+}
+
+// TimeseriesResponse represents the response from the OSRS Wiki /timeseries endpoint
+type TimeseriesResponse struct {
+	Data   []TimeseriesPoint `json:"data"`
+	ItemID int               `json:"itemId"`
+}
+
+// TimeseriesPoint represents one window of average prices and volumes for an item
+type TimeseriesPoint struct {
+	Timestamp       int64 `json:"timestamp"` // Unix start of the window
+	AvgHighPrice    int64 `json:"avgHighPrice"`
+	AvgLowPrice     int64 `json:"avgLowPrice"`
+	HighPriceVolume int64 `json:"highPriceVolume"`
+	LowPriceVolume  int64 `json:"lowPriceVolume"`
+}
+
+// ApplyItemNames fills in the ID and name of each price from the item catalog
+func ApplyItemNames(prices map[string]ItemPrice, mapping map[int]ItemMapping) {
+	for idStr, price := range prices {
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			continue
+		}
+		price.ID = id
+		if item, ok := mapping[id]; ok {
+			price.Name = item.Name
+		}
+		prices[idStr] = price
+	}
+}
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// Averaging windows offered by the OSRS Wiki API
+// /5m and /1h accept the first two; /timeseries also accepts 6h and 24h
+const (
+	Timestep5m  = "5m"
+	Timestep1h  = "1h"
+	Timestep6h  = "6h"
+	Timestep24h = "24h"
+)
+
+// PriceAverage stores average prices and trade volumes for an item over a
+// 5-minute or 1-hour window, as reported by the OSRS Wiki /5m and /1h endpoints
+type PriceAverage struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	GameMode   string    `gorm:"type:varchar(8);default:main;not null;uniqueIndex:idx_avg_item_step_time,priority:1;index:idx_avg_step_time,priority:1" json:"game_mode"`
+	ItemID     int       `gorm:"uniqueIndex:idx_avg_item_step_time;not null" json:"item_id"`
+	Timestep   string    `gorm:"type:varchar(8);uniqueIndex:idx_avg_item_step_time;index:idx_avg_step_time;not null" json:"timestep"`
+	AvgHigh    int64     `json:"avg_high"`
+	AvgLow     int64     `json:"avg_low"`
+	HighVolume int64     `json:"high_volume"`
+	LowVolume  int64     `json:"low_volume"`
+	Timestamp  time.Time `gorm:"uniqueIndex:idx_avg_item_step_time;index:idx_avg_step_time;not null" json:"timestamp"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// TableName specifies the table name for GORM
+func (PriceAverage) TableName() string {
+	return "price_averages"
+}
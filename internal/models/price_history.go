@@ -7,6 +7,7 @@ import (
 // PriceHistory stores historical price data for items
 type PriceHistory struct {
 	ID             uint      `gorm:"primaryKey" json:"id"`
+	GameMode       string    `gorm:"type:varchar(8);default:main;not null;index:idx_item_timestamp,priority:1" json:"game_mode"`
 	ItemID         int       `gorm:"index:idx_item_timestamp;not null" json:"item_id"`
 	High           int64     `json:"high"`
 	HighTime       int64     `json:"high_time"`
@@ -15,7 +16,9 @@ type PriceHistory struct {
 	HighVolume     int64     `json:"high_volume"`     // Volume of high price trades
 	LowVolume      int64     `json:"low_volume"`      // Volume of low price trades
 	Timestamp      time.Time `gorm:"index:idx_item_timestamp;not null" json:"timestamp"`
+	SnapshotID     *int64    `json:"snapshot_id,omitempty"` // Fetch the row was stored from (nil for backfilled rows)
 	CreatedAt      time.Time `json:"created_at"`
+	Resolution     string    `gorm:"-" json:"resolution,omitempty"` // Tier the point was read from (history queries only)
 }
 
 // TableName specifies the table name for GORM
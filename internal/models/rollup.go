@@ -0,0 +1,76 @@
+package models
+
+import "time"
+
+// History resolutions, one per storage tier
+// Every tier but raw is filled by the rollup worker and has a watermark.
+const (
+	ResolutionRaw     = "raw"     // 5-minute snapshots in price_history
+	ResolutionHourly  = "hourly"  // Hour buckets in price_history_hourly
+	ResolutionDaily   = "daily"   // Day buckets in price_history_daily
+	ResolutionWeekly  = "weekly"  // Monday-to-Sunday buckets in price_history_weekly
+	ResolutionMonthly = "monthly" // Calendar month buckets in price_history_monthly
+)
+
+// resolutionBuckets is the bucket size of each fixed-size tier (months vary)
+var resolutionBuckets = map[string]time.Duration{
+	ResolutionRaw:    5 * time.Minute,
+	ResolutionHourly: time.Hour,
+	ResolutionDaily:  24 * time.Hour,
+	ResolutionWeekly: 7 * 24 * time.Hour, // The zero time is a Monday, so weeks truncate to Mondays
+}
+
+// IsValidResolution reports whether resolution is a supported history resolution
+func IsValidResolution(resolution string) bool {
+	_, ok := resolutionBuckets[resolution]
+	return ok || resolution == ResolutionMonthly
+}
+
+// ResolutionForSpan picks a resolution that keeps a chart of the given span
+// to a few thousand points: raw up to a week, hourly up to 90 days, daily up
+// to two years, weekly up to ten years, then monthly
+func ResolutionForSpan(span time.Duration) string {
+	switch {
+	case span <= 7*24*time.Hour:
+		return ResolutionRaw
+	case span <= 90*24*time.Hour:
+		return ResolutionHourly
+	case span <= 2*365*24*time.Hour:
+		return ResolutionDaily
+	case span <= 10*365*24*time.Hour:
+		return ResolutionWeekly
+	default:
+		return ResolutionMonthly
+	}
+}
+
+// BucketStart returns the start of the resolution's bucket that contains t, in UTC
+func BucketStart(resolution string, t time.Time) time.Time {
+	t = t.UTC()
+	if resolution == ResolutionMonthly {
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return t.Truncate(resolutionBuckets[resolution])
+}
+
+// BucketEnd returns the end of the resolution's bucket that contains t, in UTC
+func BucketEnd(resolution string, t time.Time) time.Time {
+	start := BucketStart(resolution, t)
+	if resolution == ResolutionMonthly {
+		return start.AddDate(0, 1, 0)
+	}
+	return start.Add(resolutionBuckets[resolution])
+}
+
+// RollupWatermark records how far a rollup tier has been aggregated
+// Every bucket that ends at or before Watermark has been closed.
+type RollupWatermark struct {
+	Tier      string    `gorm:"primaryKey;type:varchar(16)" json:"tier"`
+	Watermark time.Time `gorm:"not null" json:"watermark"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for GORM
+func (RollupWatermark) TableName() string {
+	return "rollup_watermarks"
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// SnapshotInterval is how often the Wiki refreshes /latest; snapshots are
+// stamped with the start of the window they were fetched in
+const SnapshotInterval = 5 * time.Minute
+
+// KeyframeInterval is how often an item is stored even if its trade times
+// haven't changed, so a recent row to carry forward always exists
+// Reads look back at most this far for the last stored value.
+const KeyframeInterval = 24 * time.Hour
+
+// PriceSnapshot records one fetch of the latest prices
+// price_history only holds the items that changed since the previous fetch;
+// each of those rows links back to its snapshot.
+type PriceSnapshot struct {
+	ID           int64     `gorm:"primaryKey" json:"id"`
+	GameMode     string    `gorm:"type:varchar(8);not null" json:"game_mode"`
+	SnapshotTime time.Time `gorm:"not null" json:"snapshot_time"` // Start of the Wiki update window
+	FetchedAt    time.Time `gorm:"not null" json:"fetched_at"`
+	ContentHash  string    `gorm:"type:char(64);not null" json:"content_hash"`
+	ItemsTotal   int       `gorm:"not null" json:"items_total"`
+	ItemsChanged int       `gorm:"not null" json:"items_changed"`
+}
+
+// TableName specifies the table name for GORM
+func (PriceSnapshot) TableName() string {
+	return "price_snapshots"
+}
+
+// Statuses recorded in fetch_runs
+const (
+	FetchStatusSuccess  = "success"
+	FetchStatusFailed   = "failed"
+	FetchStatusReplayed = "replayed" // Saved late from the on-disk spool
+)
+
+// FetchRun records one attempt to fetch and save the latest prices
+type FetchRun struct {
+	ID         int64     `gorm:"primaryKey" json:"id"`
+	GameMode   string    `gorm:"type:varchar(8);not null" json:"game_mode"`
+	Source     string    `gorm:"type:varchar(16);not null" json:"source"`
+	StartedAt  time.Time `gorm:"not null" json:"started_at"`
+	FinishedAt time.Time `gorm:"not null" json:"finished_at"`
+	Items      int       `gorm:"not null" json:"items"`
+	Bytes      int64     `gorm:"not null" json:"bytes"`
+	Status     string    `gorm:"type:varchar(16);not null" json:"status"`
+	Error      *string   `json:"error,omitempty"`
+	SnapshotID *int64    `json:"snapshot_id,omitempty"` // Set when the fetch was saved
+}
+
+// TableName specifies the table name for GORM
+func (FetchRun) TableName() string {
+	return "fetch_runs"
+}
+
+// HashPrices returns a SHA-256 over the prices and trade times of every item,
+// independent of map order
+func HashPrices(prices map[string]ItemPrice) string {
+	ids := make([]string, 0, len(prices))
+	for id := range prices {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	h := sha256.New()
+	for _, id := range ids {
+		p := prices[id]
+		fmt.Fprintf(h, "%s:%d:%d:%d:%d\n", id, p.High, p.HighTime, p.Low, p.LowTime)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,110 @@
+package osrs
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without contacting the Wiki while the circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker open: OSRS Wiki API unavailable")
+
+// Circuit breaker states
+const (
+	BreakerClosed   = "closed"    // Requests flow normally
+	BreakerOpen     = "open"      // Requests fail fast until the cooldown ends
+	BreakerHalfOpen = "half-open" // One trial request is allowed through
+)
+
+// CircuitBreaker stops calling the Wiki after repeated failures so an outage
+// doesn't turn into a stream of doomed requests and long handler timeouts
+type CircuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	state     string
+	openedAt  time.Time
+	trial     bool
+}
+
+// NewCircuitBreaker creates a breaker that opens after threshold consecutive
+// failures and allows a trial request once cooldown has passed
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &CircuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		state:     BreakerClosed,
+	}
+}
+
+// Allow reports whether a request may be made now
+func (cb *CircuitBreaker) Allow() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case BreakerOpen:
+		if time.Since(cb.openedAt) < cb.cooldown {
+			return false
+		}
+		cb.state = BreakerHalfOpen
+		cb.trial = true
+		return true
+	case BreakerHalfOpen:
+		// Only one trial request at a time
+		if cb.trial {
+			return false
+		}
+		cb.trial = true
+		return true
+	default:
+		return true
+	}
+}
+
+// Success records a successful request and closes the breaker
+func (cb *CircuitBreaker) Success() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.failures = 0
+	cb.trial = false
+	cb.state = BreakerClosed
+}
+
+// Failure records a failed request, opening the breaker once the threshold is reached
+func (cb *CircuitBreaker) Failure() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.failures++
+	cb.trial = false
+	if cb.state == BreakerHalfOpen || cb.failures >= cb.threshold {
+		cb.state = BreakerOpen
+		cb.openedAt = time.Now()
+	}
+}
+
+// Cancel records a request that was abandoned before it said anything about
+// upstream health; a cancelled trial request sends the breaker back to open,
+// so the next caller makes a new trial instead of finding it taken forever
+func (cb *CircuitBreaker) Cancel() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state == BreakerHalfOpen && cb.trial {
+		cb.trial = false
+		cb.state = BreakerOpen
+	}
+}
+
+// State returns the current breaker state
+func (cb *CircuitBreaker) State() string {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.state
+}
@@ -0,0 +1,179 @@
+package osrs
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// openBreaker returns a breaker that has just opened after one failure
+func openBreaker(cooldown time.Duration) *CircuitBreaker {
+	cb := NewCircuitBreaker(1, cooldown)
+	cb.Allow()
+	cb.Failure()
+	return cb
+}
+
+func TestBreakerOpensAfterThreshold(t *testing.T) {
+	cb := NewCircuitBreaker(3, time.Minute)
+	for i := 0; i < 2; i++ {
+		if !cb.Allow() {
+			t.Fatalf("request %d refused before the threshold", i+1)
+		}
+		cb.Failure()
+	}
+	if state := cb.State(); state != BreakerClosed {
+		t.Fatalf("state = %s after 2 of 3 failures, want %s", state, BreakerClosed)
+	}
+
+	cb.Allow()
+	cb.Failure()
+	if state := cb.State(); state != BreakerOpen {
+		t.Fatalf("state = %s after 3 failures, want %s", state, BreakerOpen)
+	}
+	if cb.Allow() {
+		t.Error("open breaker allowed a request during its cooldown")
+	}
+}
+
+func TestBreakerAllowsOneTrial(t *testing.T) {
+	cb := openBreaker(0)
+
+	if !cb.Allow() {
+		t.Fatal("no trial request allowed after the cooldown")
+	}
+	if state := cb.State(); state != BreakerHalfOpen {
+		t.Fatalf("state = %s during the trial, want %s", state, BreakerHalfOpen)
+	}
+	if cb.Allow() {
+		t.Error("a second request was allowed during the trial")
+	}
+}
+
+func TestBreakerTrialOutcome(t *testing.T) {
+	cb := openBreaker(0)
+	cb.Allow()
+	cb.Success()
+	if state := cb.State(); state != BreakerClosed {
+		t.Errorf("state = %s after a successful trial, want %s", state, BreakerClosed)
+	}
+
+	cb = openBreaker(time.Hour)
+	cb.openedAt = time.Now().Add(-time.Hour)
+	cb.Allow()
+	cb.Failure()
+	if state := cb.State(); state != BreakerOpen {
+		t.Errorf("state = %s after a failed trial, want %s", state, BreakerOpen)
+	}
+	if cb.Allow() {
+		t.Error("a failed trial didn't restart the cooldown")
+	}
+}
+
+func TestBreakerCancelReleasesTrial(t *testing.T) {
+	cb := openBreaker(0)
+	cb.Allow()
+	cb.Cancel()
+
+	if state := cb.State(); state != BreakerOpen {
+		t.Fatalf("state = %s after a cancelled trial, want %s", state, BreakerOpen)
+	}
+	if !cb.Allow() {
+		t.Fatal("no new trial allowed after a cancelled one")
+	}
+	cb.Success()
+	if state := cb.State(); state != BreakerClosed {
+		t.Errorf("state = %s after the new trial succeeded, want %s", state, BreakerClosed)
+	}
+}
+
+func TestBreakerCancelWhileClosed(t *testing.T) {
+	cb := NewCircuitBreaker(2, time.Minute)
+	cb.Allow()
+	cb.Failure()
+	cb.Allow()
+	cb.Cancel()
+
+	if state := cb.State(); state != BreakerClosed {
+		t.Fatalf("state = %s after a cancelled request, want %s", state, BreakerClosed)
+	}
+	// The cancelled request neither reset nor added to the failure count
+	cb.Allow()
+	cb.Failure()
+	if state := cb.State(); state != BreakerOpen {
+		t.Errorf("state = %s after the second failure, want %s", state, BreakerOpen)
+	}
+}
+
+// A trial request whose caller gives up must not leave the client refusing
+// every request until restart
+func TestClientCancelledTrialDoesNotWedgeBreaker(t *testing.T) {
+	release := make(chan struct{})
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+			w.Write([]byte(`{"data":{}}`))
+		case <-r.Context().Done():
+		}
+	})
+	client.breaker = openBreaker(0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := client.fetch(ctx, "/latest"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("trial request error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if state := client.BreakerState(); state != BreakerOpen {
+		t.Fatalf("state = %s after the trial was cancelled, want %s", state, BreakerOpen)
+	}
+
+	close(release)
+	if _, err := client.fetch(context.Background(), "/latest"); err != nil {
+		t.Fatalf("request after the cancelled trial failed: %v", err)
+	}
+	if state := client.BreakerState(); state != BreakerClosed {
+		t.Errorf("state = %s after a successful request, want %s", state, BreakerClosed)
+	}
+}
+
+func TestClientCancelledDuringBackoffReleasesTrial(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	client.options.RetryBaseDelay = time.Hour
+	client.options.RetryMaxDelay = time.Hour
+	client.breaker = openBreaker(0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := client.fetch(ctx, "/mapping"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if state := client.BreakerState(); state != BreakerOpen {
+		t.Fatalf("state = %s after the trial was cancelled mid-backoff, want %s", state, BreakerOpen)
+	}
+	if !client.breaker.Allow() {
+		t.Error("no new trial allowed after the cancelled one")
+	}
+}
+
+func TestClientCancelledWaitingForGovernorReleasesTrial(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request to %s", r.URL)
+	})
+	// A governor with its only token spent makes the next request wait a minute
+	client.options.Governor = NewGovernor(2)
+	client.options.Governor.Wait(context.Background())
+	client.breaker = openBreaker(0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := client.fetch(ctx, "/latest"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if state := client.BreakerState(); state != BreakerOpen {
+		t.Fatalf("state = %s after the trial was cancelled waiting for a token, want %s", state, BreakerOpen)
+	}
+}
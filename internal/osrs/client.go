@@ -1,44 +1,225 @@
 package osrs
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
+	"math/rand"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"osrs-price-api/internal/models"
+
+	"golang.org/x/sync/singleflight"
 )
 
 const (
-	// OSRS Wiki Real-time Prices API
-	wikiAPIURL = "https://prices.runescape.wiki/api/v1/osrs/latest"
+	// OSRS Wiki Real-time Prices API (the game mode's feed is appended, e.g. /osrs)
+	wikiAPIURL = "https://prices.runescape.wiki/api/v1"
 	// User-Agent is required by the OSRS Wiki API
 	// Per https://oldschool.runescape.wiki/w/RuneScape:Real-time_Prices
 	userAgent = "grandexchange.gg - OSRS GE Price Tracker"
 )
 
+// wikiFeeds maps each game mode to its feed on the Wiki API
+var wikiFeeds = map[string]string{
+	models.GameModeMain:       "osrs",
+	models.GameModeDeadman:    "dmm",
+	models.GameModeFreshStart: "fsw",
+}
+
+// defaultFetchTimeout leaves room for every retry of a request, with backoff
+const defaultFetchTimeout = 2 * time.Minute
+
+// ClientOptions controls timeouts, retries and the circuit breaker of a Client
+type ClientOptions struct {
+	Timeout          time.Duration // Per-attempt HTTP timeout
+	MaxRetries       int           // Retries after the first attempt
+	RetryBaseDelay   time.Duration // Backoff before the first retry, doubled each retry
+	RetryMaxDelay    time.Duration // Upper bound for backoff and Retry-After waits
+	FetchTimeout     time.Duration // Upper bound for a request including its retries, independent of its callers
+	BreakerThreshold int           // Consecutive failed calls before the breaker opens
+	BreakerCooldown  time.Duration // How long the breaker stays open before a trial request
+	Governor         *Governor     // Rate governor shared by every client in the process
+}
+
+// DefaultClientOptions returns conservative settings for the public Wiki API
+func DefaultClientOptions() ClientOptions {
+	return ClientOptions{
+		Timeout:          10 * time.Second,
+		MaxRetries:       3,
+		RetryBaseDelay:   time.Second,
+		RetryMaxDelay:    30 * time.Second,
+		FetchTimeout:     defaultFetchTimeout,
+		BreakerThreshold: 5,
+		BreakerCooldown:  time.Minute,
+		Governor:         NewGovernor(60),
+	}
+}
+
 // Client handles communication with OSRS data sources
 type Client struct {
 	httpClient *http.Client
+	baseURL    string
+	options    ClientOptions
+	breaker    *CircuitBreaker
+	inflight   singleflight.Group
 }
 
-// NewClient creates a new OSRS client
+// NewClient creates a new OSRS client for the main game
 func NewClient() *Client {
+	client, _ := NewClientForMode(wikiAPIURL, models.GameModeMain, DefaultClientOptions())
+	return client
+}
+
+// NewClientForMode creates a client for one game mode's price feed
+// baseURL can point at a Wiki-compatible mirror or a local fake server;
+// the feed path (e.g. /osrs or /dmm) is appended to it.
+func NewClientForMode(baseURL, mode string, options ClientOptions) (*Client, error) {
+	feed, ok := wikiFeeds[mode]
+	if !ok {
+		return nil, fmt.Errorf("unsupported game mode: %s", mode)
+	}
+	if options.Governor == nil {
+		options.Governor = NewGovernor(60)
+	}
+	if options.FetchTimeout <= 0 {
+		options.FetchTimeout = defaultFetchTimeout
+	}
+
 	return &Client{
 		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
+			Timeout: options.Timeout,
 		},
+		baseURL: strings.TrimRight(baseURL, "/") + "/" + feed,
+		options: options,
+		breaker: NewCircuitBreaker(options.BreakerThreshold, options.BreakerCooldown),
+	}, nil
+}
+
+// BreakerState returns the state of the client's circuit breaker
+func (c *Client) BreakerState() string {
+	return c.breaker.State()
+}
+
+// GovernorStats returns the counters of the client's shared rate governor
+func (c *Client) GovernorStats() GovernorStats {
+	return c.options.Governor.Stats()
+}
+
+// statusError is returned when the Wiki responds with a non-200 status
+type statusError struct {
+	code       int
+	retryAfter time.Duration
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("API returned status %d", e.code)
+}
+
+// get performs a GET request against the Wiki API and decodes the JSON body into out
+// Concurrent calls for the same path share a single upstream request. It is
+// detached from the cancellation of the caller that started it and bounded by
+// FetchTimeout instead, so one caller giving up doesn't fail the others; each
+// caller only stops waiting when its own context is done.
+func (c *Client) get(ctx context.Context, path string, out interface{}) error {
+	leader := false
+	result := c.inflight.DoChan(path, func() (interface{}, error) {
+		leader = true
+		fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.options.FetchTimeout)
+		defer cancel()
+		return c.fetch(fetchCtx, path)
+	})
+
+	var res singleflight.Result
+	select {
+	case res = <-result:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if !leader {
+		c.options.Governor.recordCoalesced()
+	}
+	if res.Err != nil {
+		return res.Err
+	}
+
+	body := res.Val.([]byte)
+	recordResponse(ctx, body)
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
 	}
+	return nil
 }
 
-// GetLatestPrices fetches the latest prices for all items
-// Following OSRS Wiki API guidelines:
-// - Uses bulk endpoint (not individual item requests)
-// - Sets proper User-Agent
-// - Respects rate limits (called max once per 5 minutes by worker)
-func (c *Client) GetLatestPrices() (map[string]models.ItemPrice, error) {
-	req, err := http.NewRequest("GET", wikiAPIURL, nil)
+// fetch returns the body of a successful GET request against the Wiki API
+// Network errors, 429 and 5xx responses are retried with exponential backoff and
+// jitter, honoring Retry-After. Calls fail fast while the circuit breaker is open,
+// and every attempt waits for a token from the shared governor.
+func (c *Client) fetch(ctx context.Context, path string) ([]byte, error) {
+	if !c.breaker.Allow() {
+		return nil, ErrCircuitOpen
+	}
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		var body []byte
+		body, err = c.do(ctx, path)
+		if err == nil {
+			c.breaker.Success()
+			return body, nil
+		}
+
+		// The caller gave up; that says nothing about upstream health
+		if ctx.Err() != nil {
+			c.breaker.Cancel()
+			return nil, ctx.Err()
+		}
+
+		var statusErr *statusError
+		if errors.As(err, &statusErr) && statusErr.code < 500 && statusErr.code != http.StatusTooManyRequests {
+			// The Wiki is up but rejected this request; retrying won't help
+			// and it says nothing about upstream health
+			c.breaker.Success()
+			return nil, err
+		}
+
+		if attempt >= c.options.MaxRetries {
+			break
+		}
+
+		delay := c.backoff(attempt)
+		if statusErr != nil && statusErr.retryAfter > 0 {
+			if statusErr.retryAfter > c.options.RetryMaxDelay {
+				break
+			}
+			delay = statusErr.retryAfter
+		}
+
+		log.Printf("Wiki request %s failed (attempt %d/%d): %v, retrying in %s",
+			path, attempt+1, c.options.MaxRetries+1, err, delay.Round(time.Millisecond))
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			c.breaker.Cancel()
+			return nil, ctx.Err()
+		}
+	}
+
+	c.breaker.Failure()
+	return nil, err
+}
+
+// do makes a single request attempt
+func (c *Client) do(ctx context.Context, path string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+path, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -46,14 +227,21 @@ func (c *Client) GetLatestPrices() (map[string]models.ItemPrice, error) {
 	// OSRS Wiki API requires a User-Agent header
 	req.Header.Set("User-Agent", userAgent)
 
+	if err := c.options.Governor.Wait(ctx); err != nil {
+		return nil, err
+	}
+
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch prices: %w", err)
+		return nil, fmt.Errorf("failed to fetch %s: %w", path, err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API returned status %d", resp.StatusCode)
+		return nil, &statusError{
+			code:       resp.StatusCode,
+			retryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
 	}
 
 	body, err := io.ReadAll(resp.Body)
@@ -61,12 +249,57 @@ func (c *Client) GetLatestPrices() (map[string]models.ItemPrice, error) {
 		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
+	return body, nil
+}
+
+// backoff returns the wait before retry number attempt+1: exponential with
+// jitter in [delay/2, delay] so concurrent clients don't retry in lockstep
+func (c *Client) backoff(attempt int) time.Duration {
+	delay := c.options.RetryBaseDelay << attempt
+	if delay <= 0 || delay > c.options.RetryMaxDelay {
+		delay = c.options.RetryMaxDelay
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if when, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(when); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
+
+// Name returns SourceWiki
+func (c *Client) Name() string {
+	return SourceWiki
+}
+
+// GetLatestPrices fetches the latest prices for all items
+// Following OSRS Wiki API guidelines:
+// - Uses bulk endpoint (not individual item requests)
+// - Sets proper User-Agent
+// - Respects rate limits (called max once per 5 minutes by worker)
+func (c *Client) GetLatestPrices(ctx context.Context) (map[string]models.ItemPrice, error) {
 	var wikiResp models.OSRSWikiResponse
-	if err := json.Unmarshal(body, &wikiResp); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+	if err := c.get(ctx, "/latest", &wikiResp); err != nil {
+		return nil, err
 	}
 
-	// Convert to our internal format
+	return toItemPrices(&wikiResp), nil
+}
+
+// toItemPrices converts a Wiki /latest response to our internal format
+func toItemPrices(wikiResp *models.OSRSWikiResponse) map[string]models.ItemPrice {
 	prices := make(map[string]models.ItemPrice)
 	for idStr, data := range wikiResp.Data {
 		prices[idStr] = models.ItemPrice{
@@ -79,15 +312,15 @@ func (c *Client) GetLatestPrices() (map[string]models.ItemPrice, error) {
 		}
 	}
 
-	return prices, nil
+	return prices
 }
 
 // GetItemPrice fetches the price for a specific item by ID
 // Note: This correctly uses the bulk endpoint internally (GetLatestPrices)
 // and extracts the single item, following OSRS Wiki best practices.
 // We never make individual API calls per item to avoid hammering their servers.
-func (c *Client) GetItemPrice(itemID string) (*models.ItemPrice, error) {
-	prices, err := c.GetLatestPrices()
+func (c *Client) GetItemPrice(ctx context.Context, itemID string) (*models.ItemPrice, error) {
+	prices, err := c.GetLatestPrices(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -98,4 +331,55 @@ func (c *Client) GetItemPrice(itemID string) (*models.ItemPrice, error) {
 	}
 
 	return &price, nil
-}
\ No newline at end of file
+}
+
+// GetMapping fetches the item catalog (names, alch values, buy limits, etc.)
+// The mapping changes only when the game is updated, so it should be
+// fetched infrequently (the mapping worker refreshes it daily).
+func (c *Client) GetMapping(ctx context.Context) ([]models.ItemMapping, error) {
+	var mapping []models.ItemMapping
+	if err := c.get(ctx, "/mapping", &mapping); err != nil {
+		return nil, err
+	}
+	return mapping, nil
+}
+
+// GetAveragePrices fetches average high/low prices and trade volumes for all
+// items over a 5-minute or 1-hour window (timestep is models.Timestep5m or
+// models.Timestep1h). A zero timestamp requests the most recent window;
+// otherwise timestamp must be the Unix start of a window aligned to the timestep.
+func (c *Client) GetAveragePrices(ctx context.Context, timestep string, timestamp int64) (*models.AveragePriceResponse, error) {
+	if timestep != models.Timestep5m && timestep != models.Timestep1h {
+		return nil, fmt.Errorf("unsupported timestep: %s", timestep)
+	}
+
+	path := "/" + timestep
+	if timestamp > 0 {
+		path += "?timestamp=" + strconv.FormatInt(timestamp, 10)
+	}
+
+	var wikiResp models.AveragePriceResponse
+	if err := c.get(ctx, path, &wikiResp); err != nil {
+		return nil, err
+	}
+	return &wikiResp, nil
+}
+
+// GetTimeseries fetches up to 365 windows of average prices and volumes for one item
+// timestep is one of models.Timestep5m, Timestep1h, Timestep6h or Timestep24h.
+// Unlike the bulk endpoints this is a per-item request, so callers must pace
+// themselves when iterating over many items.
+func (c *Client) GetTimeseries(ctx context.Context, itemID int, timestep string) ([]models.TimeseriesPoint, error) {
+	switch timestep {
+	case models.Timestep5m, models.Timestep1h, models.Timestep6h, models.Timestep24h:
+	default:
+		return nil, fmt.Errorf("unsupported timestep: %s", timestep)
+	}
+
+	var wikiResp models.TimeseriesResponse
+	path := fmt.Sprintf("/timeseries?id=%d&timestep=%s", itemID, timestep)
+	if err := c.get(ctx, path, &wikiResp); err != nil {
+		return nil, err
+	}
+	return wikiResp.Data, nil
+}
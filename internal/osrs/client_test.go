@@ -0,0 +1,169 @@
+package osrs
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"osrs-price-api/internal/models"
+)
+
+// newTestClient returns a main-game client for a fake Wiki server, with fast
+// retries and a governor that never throttles
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client, err := NewClientForMode(server.URL, models.GameModeMain, ClientOptions{
+		Timeout:          time.Second,
+		MaxRetries:       2,
+		RetryBaseDelay:   time.Millisecond,
+		RetryMaxDelay:    10 * time.Millisecond,
+		BreakerThreshold: 3,
+		BreakerCooldown:  time.Minute,
+		Governor:         NewGovernor(60000),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestGetTimeseries(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/osrs/timeseries" {
+			t.Errorf("path = %s, want /osrs/timeseries", r.URL.Path)
+		}
+		if id, step := r.URL.Query().Get("id"), r.URL.Query().Get("timestep"); id != "4151" || step != "5m" {
+			t.Errorf("query = id=%s timestep=%s, want id=4151 timestep=5m", id, step)
+		}
+		if r.Header.Get("User-Agent") != userAgent {
+			t.Errorf("User-Agent = %q, want %q", r.Header.Get("User-Agent"), userAgent)
+		}
+		w.Write([]byte(`{"data":[
+			{"timestamp":1759992800,"avgHighPrice":1498000,"avgLowPrice":1478000,"highPriceVolume":3,"lowPriceVolume":6},
+			{"timestamp":1759993100,"avgHighPrice":null,"avgLowPrice":1478500,"highPriceVolume":0,"lowPriceVolume":7}
+		],"itemId":4151}`))
+	})
+
+	points, err := client.GetTimeseries(context.Background(), 4151, models.Timestep5m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []models.TimeseriesPoint{
+		{Timestamp: 1759992800, AvgHighPrice: 1498000, AvgLowPrice: 1478000, HighPriceVolume: 3, LowPriceVolume: 6},
+		{Timestamp: 1759993100, AvgHighPrice: 0, AvgLowPrice: 1478500, HighPriceVolume: 0, LowPriceVolume: 7},
+	}
+	if len(points) != len(want) {
+		t.Fatalf("got %d points, want %d", len(points), len(want))
+	}
+	for i := range want {
+		if points[i] != want[i] {
+			t.Errorf("point %d = %+v, want %+v", i, points[i], want[i])
+		}
+	}
+}
+
+func TestGetTimeseriesRejectsUnknownTimestep(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request to %s", r.URL)
+	})
+
+	if _, err := client.GetTimeseries(context.Background(), 4151, "2m"); err == nil {
+		t.Fatal("expected an error for an unsupported timestep")
+	}
+}
+
+func TestGetTimeseriesNotFound(t *testing.T) {
+	requests := 0
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	if _, err := client.GetTimeseries(context.Background(), 4151, models.Timestep1h); err == nil {
+		t.Fatal("expected an error for a 404 response")
+	}
+	if requests != 1 {
+		t.Errorf("made %d requests, want 1 (4xx responses are not retried)", requests)
+	}
+	if state := client.BreakerState(); state != BreakerClosed {
+		t.Errorf("breaker is %s after a 4xx response, want %s", state, BreakerClosed)
+	}
+}
+
+// A coalesced request keeps going for the other callers when the caller that
+// started it gives up
+func TestCoalescedRequestOutlivesFirstCaller(t *testing.T) {
+	var requests atomic.Int32
+	received := make(chan struct{})
+	release := make(chan struct{})
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			close(received)
+		}
+		<-release
+		w.Write([]byte(`{"data":{"4151":{"high":1500000,"highTime":1759992800,"low":1480000,"lowTime":1759992790}}}`))
+	})
+
+	firstCtx, cancelFirst := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := client.GetLatestPrices(firstCtx)
+		firstErr <- err
+	}()
+	<-received
+
+	type result struct {
+		prices int
+		err    error
+	}
+	second := make(chan result, 1)
+	go func() {
+		prices, err := client.GetLatestPrices(context.Background())
+		second <- result{len(prices), err}
+	}()
+	// Give the second caller time to join the request in flight
+	time.Sleep(20 * time.Millisecond)
+
+	cancelFirst()
+	if err := <-firstErr; !errors.Is(err, context.Canceled) {
+		t.Fatalf("first caller error = %v, want %v", err, context.Canceled)
+	}
+
+	close(release)
+	res := <-second
+	if res.err != nil {
+		t.Fatalf("second caller failed after the first gave up: %v", res.err)
+	}
+	if res.prices != 1 {
+		t.Errorf("second caller got %d prices, want 1", res.prices)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("made %d upstream requests, want 1", n)
+	}
+	if coalesced := client.GovernorStats().Coalesced; coalesced != 1 {
+		t.Errorf("coalesced = %d, want 1", coalesced)
+	}
+	if state := client.BreakerState(); state != BreakerClosed {
+		t.Errorf("breaker is %s, want %s", state, BreakerClosed)
+	}
+}
+
+func TestCoalescedRequestIsBoundedByFetchTimeout(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+	client.options.MaxRetries = 0
+	client.options.FetchTimeout = 20 * time.Millisecond
+
+	if _, err := client.GetLatestPrices(context.Background()); err == nil {
+		t.Fatal("expected the request to time out")
+	}
+}
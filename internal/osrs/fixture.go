@@ -0,0 +1,170 @@
+package osrs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"osrs-price-api/internal/models"
+)
+
+// FixtureSource replays recorded OSRS Wiki responses from a directory
+// The directory uses the raw Wiki JSON format, so fixtures can be recorded with curl:
+//
+//	latest*.json                   /latest snapshots, replayed in name order and looped
+//	mapping.json                   /mapping
+//	5m*.json, 1h*.json             /5m and /1h windows, replayed in name order and looped
+//	timeseries/<id>_<timestep>.json  /timeseries?id=<id>&timestep=<timestep>
+type FixtureSource struct {
+	dir      string
+	mu       sync.Mutex
+	next     map[string]int
+	snapshot map[string][]string
+}
+
+// NewFixtureSource creates a price source backed by the fixture directory
+func NewFixtureSource(dir string) (*FixtureSource, error) {
+	fs := &FixtureSource{
+		dir:      dir,
+		next:     make(map[string]int),
+		snapshot: make(map[string][]string),
+	}
+
+	for _, prefix := range []string{"latest", models.Timestep5m, models.Timestep1h} {
+		files, err := filepath.Glob(filepath.Join(dir, prefix+"*.json"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s fixtures: %w", prefix, err)
+		}
+		sort.Strings(files)
+		fs.snapshot[prefix] = files
+	}
+
+	if len(fs.snapshot["latest"]) == 0 {
+		return nil, fmt.Errorf("no latest*.json fixtures found in %s", dir)
+	}
+
+	return fs, nil
+}
+
+// nextFile returns the next recorded snapshot for prefix, looping at the end
+func (fs *FixtureSource) nextFile(prefix string) (string, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	files := fs.snapshot[prefix]
+	if len(files) == 0 {
+		return "", fmt.Errorf("no %s*.json fixtures found in %s", prefix, fs.dir)
+	}
+
+	file := files[fs.next[prefix]%len(files)]
+	fs.next[prefix]++
+	return file, nil
+}
+
+// load reads a fixture file and decodes it into out
+func (fs *FixtureSource) load(ctx context.Context, path string, out interface{}) error {
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read fixture: %w", err)
+	}
+	recordResponse(ctx, body)
+
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to parse fixture %s: %w", path, err)
+	}
+	return nil
+}
+
+// Name returns SourceFixture
+func (fs *FixtureSource) Name() string {
+	return SourceFixture
+}
+
+// GetLatestPrices replays the next recorded /latest snapshot
+func (fs *FixtureSource) GetLatestPrices(ctx context.Context) (map[string]models.ItemPrice, error) {
+	file, err := fs.nextFile("latest")
+	if err != nil {
+		return nil, err
+	}
+
+	var wikiResp models.OSRSWikiResponse
+	if err := fs.load(ctx, file, &wikiResp); err != nil {
+		return nil, err
+	}
+	return toItemPrices(&wikiResp), nil
+}
+
+// GetItemPrice extracts one item from the next recorded /latest snapshot
+func (fs *FixtureSource) GetItemPrice(ctx context.Context, itemID string) (*models.ItemPrice, error) {
+	prices, err := fs.GetLatestPrices(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	price, exists := prices[itemID]
+	if !exists {
+		return nil, fmt.Errorf("item not found")
+	}
+
+	return &price, nil
+}
+
+// GetMapping returns the recorded item catalog
+func (fs *FixtureSource) GetMapping(ctx context.Context) ([]models.ItemMapping, error) {
+	var mapping []models.ItemMapping
+	if err := fs.load(ctx, filepath.Join(fs.dir, "mapping.json"), &mapping); err != nil {
+		return nil, err
+	}
+	return mapping, nil
+}
+
+// GetAveragePrices replays the next recorded /5m or /1h window
+// The window is restamped to the requested timestamp (or the most recent closed
+// window) so workers see a continuous series while the recordings loop.
+func (fs *FixtureSource) GetAveragePrices(ctx context.Context, timestep string, timestamp int64) (*models.AveragePriceResponse, error) {
+	var window time.Duration
+	switch timestep {
+	case models.Timestep5m:
+		window = 5 * time.Minute
+	case models.Timestep1h:
+		window = time.Hour
+	default:
+		return nil, fmt.Errorf("unsupported timestep: %s", timestep)
+	}
+
+	file, err := fs.nextFile(timestep)
+	if err != nil {
+		return nil, err
+	}
+
+	var wikiResp models.AveragePriceResponse
+	if err := fs.load(ctx, file, &wikiResp); err != nil {
+		return nil, err
+	}
+
+	if timestamp > 0 {
+		wikiResp.Timestamp = timestamp
+	} else {
+		wikiResp.Timestamp = time.Now().UTC().Truncate(window).Add(-window).Unix()
+	}
+	return &wikiResp, nil
+}
+
+// GetTimeseries returns the recorded timeseries for one item
+func (fs *FixtureSource) GetTimeseries(ctx context.Context, itemID int, timestep string) ([]models.TimeseriesPoint, error) {
+	path := filepath.Join(fs.dir, "timeseries", fmt.Sprintf("%d_%s.json", itemID, timestep))
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, fmt.Errorf("no %s timeseries fixture for item %d", timestep, itemID)
+	}
+
+	var wikiResp models.TimeseriesResponse
+	if err := fs.load(ctx, path, &wikiResp); err != nil {
+		return nil, err
+	}
+	return wikiResp.Data, nil
+}
@@ -0,0 +1,123 @@
+package osrs
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Governor is a process-wide token bucket shared by every Wiki client
+// It guarantees that no more than the configured number of requests reach
+// the Wiki in any one-minute window, so the service can't get its User-Agent
+// banned no matter how many workers, handlers or backfills are running.
+type Governor struct {
+	mu        sync.Mutex
+	perMinute int
+	burst     float64
+	rate      float64 // tokens per second
+	tokens    float64
+	last      time.Time
+
+	requests  uint64
+	throttled uint64
+	coalesced uint64
+	waited    time.Duration
+}
+
+// GovernorStats is a snapshot of the governor's counters for monitoring
+type GovernorStats struct {
+	MaxPerMinute     int     `json:"max_per_minute"`
+	Burst            int     `json:"burst"`
+	AvailableTokens  float64 `json:"available_tokens"`
+	Requests         uint64  `json:"requests"`           // Requests allowed through to the Wiki
+	Throttled        uint64  `json:"throttled"`          // Requests that had to wait for a token
+	Coalesced        uint64  `json:"coalesced"`          // Calls served by an identical in-flight request
+	TotalWaitSeconds float64 `json:"total_wait_seconds"` // Time spent waiting for tokens
+}
+
+// NewGovernor creates a governor allowing at most perMinute requests per minute
+// The bucket holds a small burst and refills at perMinute-burst per minute, so
+// even a full burst followed by a minute of steady traffic stays within the limit.
+func NewGovernor(perMinute int) *Governor {
+	if perMinute < 2 {
+		perMinute = 2
+	}
+
+	burst := perMinute / 10
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &Governor{
+		perMinute: perMinute,
+		burst:     float64(burst),
+		rate:      float64(perMinute-burst) / 60,
+		tokens:    float64(burst),
+		last:      time.Now(),
+	}
+}
+
+// Wait blocks until a request may be sent to the Wiki or ctx is done
+func (g *Governor) Wait(ctx context.Context) error {
+	throttled := false
+	for {
+		g.mu.Lock()
+		now := time.Now()
+		g.tokens += now.Sub(g.last).Seconds() * g.rate
+		if g.tokens > g.burst {
+			g.tokens = g.burst
+		}
+		g.last = now
+
+		if g.tokens >= 1 {
+			g.tokens--
+			g.requests++
+			g.mu.Unlock()
+			return nil
+		}
+
+		wait := time.Duration((1 - g.tokens) / g.rate * float64(time.Second))
+		if !throttled {
+			g.throttled++
+			throttled = true
+		}
+		g.waited += wait
+		g.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// recordCoalesced counts a call that was served by an in-flight request
+func (g *Governor) recordCoalesced() {
+	g.mu.Lock()
+	g.coalesced++
+	g.mu.Unlock()
+}
+
+// Stats returns the governor's counters
+func (g *Governor) Stats() GovernorStats {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	available := g.tokens + time.Since(g.last).Seconds()*g.rate
+	if available > g.burst {
+		available = g.burst
+	}
+
+	return GovernorStats{
+		MaxPerMinute:     g.perMinute,
+		Burst:            int(g.burst),
+		AvailableTokens:  available,
+		Requests:         g.requests,
+		Throttled:        g.throttled,
+		Coalesced:        g.coalesced,
+		TotalWaitSeconds: g.waited.Seconds(),
+	}
+}
@@ -0,0 +1,147 @@
+package osrs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"osrs-price-api/internal/models"
+)
+
+// PriceSource provides OSRS price data to the workers and API handlers
+// The OSRS Wiki client is the production implementation; FixtureSource
+// replays recorded Wiki responses for offline development and CI.
+// Every call is bound to ctx, so cancelling it aborts any in-flight request.
+type PriceSource interface {
+	// Name returns the source type, SourceWiki or SourceFixture
+	Name() string
+	// GetLatestPrices returns the latest instant prices for all items keyed by item ID
+	GetLatestPrices(ctx context.Context) (map[string]models.ItemPrice, error)
+	// GetItemPrice returns the latest instant price for one item
+	GetItemPrice(ctx context.Context, itemID string) (*models.ItemPrice, error)
+	// GetMapping returns the item catalog
+	GetMapping(ctx context.Context) ([]models.ItemMapping, error)
+	// GetAveragePrices returns one 5m or 1h window of average prices and volumes
+	GetAveragePrices(ctx context.Context, timestep string, timestamp int64) (*models.AveragePriceResponse, error)
+	// GetTimeseries returns historical windows of average prices for one item
+	GetTimeseries(ctx context.Context, itemID int, timestep string) ([]models.TimeseriesPoint, error)
+}
+
+// Available price source types
+const (
+	SourceWiki    = "wiki"
+	SourceFixture = "fixture"
+)
+
+// Config holds price source configuration
+type Config struct {
+	Source     string   // "wiki" (default) or "fixture"
+	WikiURL    string   // Base URL for the Wiki API, without the game mode feed
+	FixtureDir string   // Directory of recorded responses for the fixture source
+	GameModes  []string // Game modes to track (main, dmm, fsw)
+	Client     ClientOptions
+}
+
+// LoadConfig loads price source configuration from environment variables
+func LoadConfig() *Config {
+	var modes []string
+	for _, mode := range strings.Split(getEnv("GAME_MODES", models.GameModeMain), ",") {
+		if mode = strings.TrimSpace(mode); mode != "" {
+			modes = append(modes, mode)
+		}
+	}
+
+	defaults := DefaultClientOptions()
+	return &Config{
+		Source:     getEnv("PRICE_SOURCE", SourceWiki),
+		WikiURL:    getEnv("WIKI_API_URL", wikiAPIURL),
+		FixtureDir: getEnv("PRICE_FIXTURE_DIR", "fixtures/sample"),
+		GameModes:  modes,
+		Client: ClientOptions{
+			Timeout:          getEnvDuration("WIKI_TIMEOUT", defaults.Timeout),
+			MaxRetries:       getEnvInt("WIKI_MAX_RETRIES", defaults.MaxRetries),
+			RetryBaseDelay:   getEnvDuration("WIKI_RETRY_BASE_DELAY", defaults.RetryBaseDelay),
+			RetryMaxDelay:    getEnvDuration("WIKI_RETRY_MAX_DELAY", defaults.RetryMaxDelay),
+			FetchTimeout:     getEnvDuration("WIKI_FETCH_TIMEOUT", defaults.FetchTimeout),
+			BreakerThreshold: getEnvInt("WIKI_BREAKER_THRESHOLD", defaults.BreakerThreshold),
+			BreakerCooldown:  getEnvDuration("WIKI_BREAKER_COOLDOWN", defaults.BreakerCooldown),
+			// One governor for the whole process, shared by every game mode's client
+			Governor: NewGovernor(getEnvInt("WIKI_MAX_REQUESTS_PER_MINUTE", 60)),
+		},
+	}
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value, err := time.ParseDuration(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+// NewSource creates the price source selected by the configuration for one game mode
+// Fixtures for the main game live in FixtureDir; other modes use a subdirectory
+// named after the mode (e.g. fixtures/sample/dmm), or the main game's fixtures
+// when there is none.
+func NewSource(config *Config, mode string) (PriceSource, error) {
+	if !models.IsValidGameMode(mode) {
+		return nil, fmt.Errorf("unsupported game mode: %s", mode)
+	}
+
+	switch config.Source {
+	case SourceWiki, "":
+		return NewClientForMode(config.WikiURL, mode, config.Client)
+	case SourceFixture:
+		dir := config.FixtureDir
+		if mode != models.GameModeMain {
+			if modeDir := filepath.Join(dir, mode); isDir(modeDir) {
+				dir = modeDir
+			} else {
+				log.Printf("No %s fixtures in %s, replaying the main game's fixtures for %s", mode, modeDir, mode)
+			}
+		}
+		return NewFixtureSource(dir)
+	default:
+		return nil, fmt.Errorf("unknown price source: %s (available: %s, %s)", config.Source, SourceWiki, SourceFixture)
+	}
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// NewSources creates a price source for every configured game mode, keyed by mode
+func NewSources(config *Config) (map[string]PriceSource, error) {
+	if len(config.GameModes) == 0 {
+		return nil, fmt.Errorf("no game modes configured")
+	}
+
+	sources := make(map[string]PriceSource, len(config.GameModes))
+	for _, mode := range config.GameModes {
+		source, err := NewSource(config, mode)
+		if err != nil {
+			return nil, err
+		}
+		sources[mode] = source
+	}
+	return sources, nil
+}
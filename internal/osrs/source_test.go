@@ -0,0 +1,63 @@
+package osrs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"osrs-price-api/internal/models"
+)
+
+func TestNewSourcesFixtureModes(t *testing.T) {
+	config := &Config{
+		Source:     SourceFixture,
+		FixtureDir: filepath.Join("..", "..", "fixtures", "sample"),
+		GameModes:  []string{models.GameModeMain, models.GameModeDeadman, models.GameModeFreshStart},
+	}
+
+	sources, err := NewSources(config)
+	if err != nil {
+		t.Fatalf("fixture sources for every mode: %v", err)
+	}
+	for _, mode := range config.GameModes {
+		prices, err := sources[mode].GetLatestPrices(context.Background())
+		if err != nil {
+			t.Fatalf("%s: %v", mode, err)
+		}
+		if len(prices) == 0 {
+			t.Errorf("%s: no prices", mode)
+		}
+	}
+}
+
+func TestNewSourceUsesModeFixtures(t *testing.T) {
+	dir := t.TempDir()
+	writeFixture(t, filepath.Join(dir, "latest_001.json"), `{"data":{"4151":{"high":1500000,"highTime":1759992800}}}`)
+	writeFixture(t, filepath.Join(dir, models.GameModeDeadman, "latest_001.json"), `{"data":{"4151":{"high":2000,"highTime":1759992800},"11802":{"high":3000,"highTime":1759992800}}}`)
+
+	config := &Config{Source: SourceFixture, FixtureDir: dir}
+	for mode, want := range map[string]int{models.GameModeDeadman: 2, models.GameModeFreshStart: 1} {
+		source, err := NewSource(config, mode)
+		if err != nil {
+			t.Fatalf("%s: %v", mode, err)
+		}
+		prices, err := source.GetLatestPrices(context.Background())
+		if err != nil {
+			t.Fatalf("%s: %v", mode, err)
+		}
+		if len(prices) != want {
+			t.Errorf("%s: got %d prices, want %d", mode, len(prices), want)
+		}
+	}
+}
+
+func writeFixture(t *testing.T, path, body string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
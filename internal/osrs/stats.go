@@ -0,0 +1,25 @@
+package osrs
+
+import "context"
+
+// ResponseStats collects what a price source read to answer the calls made
+// with a context from WithResponseStats
+// It is not safe for concurrent calls sharing one context.
+type ResponseStats struct {
+	Bytes int64 // Response bodies read, before decoding
+}
+
+type responseStatsKey struct{}
+
+// WithResponseStats returns a context that makes price sources record their
+// responses into stats
+func WithResponseStats(ctx context.Context, stats *ResponseStats) context.Context {
+	return context.WithValue(ctx, responseStatsKey{}, stats)
+}
+
+// recordResponse adds a response body to the stats attached to ctx, if any
+func recordResponse(ctx context.Context, body []byte) {
+	if stats, ok := ctx.Value(responseStatsKey{}).(*ResponseStats); ok {
+		stats.Bytes += int64(len(body))
+	}
+}
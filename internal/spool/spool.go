@@ -0,0 +1,508 @@
+package spool
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"osrs-price-api/internal/models"
+)
+
+// Spool files are gzip-compressed: a header line with the SHA-256 of the
+// payload, then the JSON-encoded Entry
+const (
+	fileSuffix    = ".snap.gz"
+	corruptSuffix = ".corrupt"
+	headerPrefix  = "osrs-spool v1 sha256:"
+	droppedFile   = "dropped.jsonl" // Fetch runs of dropped entries, one JSON object per line
+)
+
+// ErrCorrupt is returned for a spool file whose checksum doesn't match
+var ErrCorrupt = errors.New("spool file is corrupt")
+
+// Config holds spool configuration
+type Config struct {
+	Dir      string // Base directory; each game mode spools into its own subdirectory
+	MaxBytes int64  // Compressed size cap per game mode
+	MaxFiles int    // Entry count cap per game mode
+}
+
+// LoadConfig loads spool configuration from environment variables
+func LoadConfig() *Config {
+	return &Config{
+		Dir:      getEnv("SPOOL_DIR", "spool"),
+		MaxBytes: int64(getEnvInt("SPOOL_MAX_MB", 256)) << 20,
+		MaxFiles: getEnvInt("SPOOL_MAX_FILES", 10000),
+	}
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+// Entry is a fetched snapshot that could not be saved to the database
+type Entry struct {
+	Mode      string                      `json:"mode"`
+	FetchedAt time.Time                   `json:"fetched_at"`
+	Prices    map[string]models.ItemPrice `json:"prices"`
+	Run       models.FetchRun             `json:"run"`   // The fetch, as it would have been recorded
+	Error     string                      `json:"error"` // Why saving failed
+}
+
+// Stats describes a spool's backlog
+type Stats struct {
+	Mode        string     `json:"mode"`
+	Dir         string     `json:"dir"`
+	Entries     int        `json:"entries"`
+	Bytes       int64      `json:"bytes"`
+	Oldest      *time.Time `json:"oldest,omitempty"`
+	Newest      *time.Time `json:"newest,omitempty"`
+	MaxBytes    int64      `json:"max_bytes"`
+	MaxFiles    int        `json:"max_files"`
+	Dropped     int64      `json:"dropped"`      // Entries discarded to stay within the caps since startup
+	DroppedRuns int        `json:"dropped_runs"` // Dropped entries whose failed fetch run isn't recorded yet
+	Corrupt     int        `json:"corrupt"`      // Files set aside because their checksum didn't match
+	LastReplay  *time.Time `json:"last_replay,omitempty"`
+	LastError   string     `json:"last_error,omitempty"` // Why the last replay stopped, if it did
+}
+
+// Spool is an append-only directory of snapshots for one game mode, kept
+// until they can be replayed into the database
+// Each entry is its own file named after its fetch time, written to a
+// temporary file and renamed into place, so a crash never leaves half an
+// entry. When the caps are exceeded the oldest entries are dropped, keeping
+// their fetch runs to be recorded as failed.
+type Spool struct {
+	mode     string
+	dir      string
+	maxBytes int64
+	maxFiles int
+
+	replayMu sync.Mutex // Held for a whole replay, so replays never overlap
+
+	mu         sync.Mutex // Guards the files and the fields below, never held across a replay callback
+	replaying  string     // Entry being handed to a replay callback, which Append must not drop
+	dropped    int64
+	lastReplay time.Time
+	lastErr    error
+}
+
+// Open creates the spool directory for a game mode if needed
+func Open(config *Config, mode string) (*Spool, error) {
+	dir := filepath.Join(config.Dir, mode)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating spool directory: %w", err)
+	}
+	return &Spool{mode: mode, dir: dir, maxBytes: config.MaxBytes, maxFiles: config.MaxFiles}, nil
+}
+
+// Append durably writes an entry, then drops the oldest entries while the
+// spool is over its caps (never the one just written, nor one being
+// replayed); the fetch run of each dropped entry is kept as failed until
+// ReplayDropped records it
+func (s *Spool) Append(entry *Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	payload, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(payload)
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	fmt.Fprintf(zw, "%s%s\n", headerPrefix, hex.EncodeToString(sum[:]))
+	zw.Write(payload)
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%020d%s", entry.FetchedAt.UnixNano(), fileSuffix)
+	if err := writeFileSync(filepath.Join(s.dir, name), buf.Bytes()); err != nil {
+		return fmt.Errorf("writing spool entry: %w", err)
+	}
+
+	files, err := s.list()
+	if err != nil {
+		return err
+	}
+	var total int64
+	for _, f := range files {
+		total += f.size
+	}
+	count := len(files)
+	for _, f := range files[:len(files)-1] {
+		if total <= s.maxBytes && count <= s.maxFiles {
+			break
+		}
+		if f.name == s.replaying {
+			continue
+		}
+		if err := s.drop(f); err != nil {
+			return err
+		}
+		total -= f.size
+		count--
+	}
+	return nil
+}
+
+// drop deletes an entry to make room, first keeping its fetch run as failed
+// so the fetch is still recorded; s.mu must be held
+func (s *Spool) drop(f spoolFile) error {
+	path := filepath.Join(s.dir, f.name)
+	if entry, err := readEntry(path); err == nil {
+		run := entry.Run
+		run.Status = models.FetchStatusFailed
+		message := fmt.Sprintf("snapshot dropped from the spool to stay within its caps; saving it failed: %s", entry.Error)
+		run.Error = &message
+		if err := s.appendDropped(&run); err != nil {
+			return fmt.Errorf("recording dropped spool entry: %w", err)
+		}
+	} else {
+		log.Printf("Dropping unreadable spool file %s without a fetch run: %v", path, err)
+	}
+
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("dropping spool entry: %w", err)
+	}
+	log.Printf("Spool %s over its cap: dropped entry fetched at %s", s.mode, f.fetchedAt.Format(time.RFC3339))
+	s.dropped++
+	return nil
+}
+
+// appendDropped durably adds a fetch run to the dropped file; s.mu must be held
+func (s *Spool) appendDropped(run *models.FetchRun) error {
+	line, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(s.dir, droppedFile), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// readDropped returns the fetch runs in the dropped file, oldest first;
+// s.mu must be held
+func (s *Spool) readDropped() ([]models.FetchRun, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, droppedFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var runs []models.FetchRun
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var run models.FetchRun
+		if err := json.Unmarshal(line, &run); err != nil {
+			// A line cut short by a crash
+			log.Printf("Skipping unreadable line in %s: %v", droppedFile, err)
+			continue
+		}
+		runs = append(runs, run)
+	}
+	return runs, nil
+}
+
+// ReplayDropped hands the fetch runs of dropped entries to fn, oldest first,
+// stopping at the first error, and forgets those fn accepted. It returns how
+// many were accepted.
+// A crash before they are forgotten hands them over again.
+func (s *Spool) ReplayDropped(fn func(*models.FetchRun) error) (int, error) {
+	s.replayMu.Lock()
+	defer s.replayMu.Unlock()
+
+	s.mu.Lock()
+	runs, err := s.readDropped()
+	s.mu.Unlock()
+	if err != nil || len(runs) == 0 {
+		return 0, err
+	}
+
+	accepted := 0
+	var fnErr error
+	for i := range runs {
+		if fnErr = fn(&runs[i]); fnErr != nil {
+			break
+		}
+		accepted++
+	}
+
+	// Append may have added runs since they were read; keep those
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, err := s.readDropped()
+	if err != nil {
+		return accepted, err
+	}
+	path := filepath.Join(s.dir, droppedFile)
+	if remaining := current[min(accepted, len(current)):]; len(remaining) == 0 {
+		err = os.Remove(path)
+	} else {
+		var buf bytes.Buffer
+		for i := range remaining {
+			line, err := json.Marshal(&remaining[i])
+			if err != nil {
+				return accepted, err
+			}
+			buf.Write(append(line, '\n'))
+		}
+		err = writeFileSync(path, buf.Bytes())
+	}
+	if err != nil {
+		return accepted, fmt.Errorf("updating %s: %w", droppedFile, err)
+	}
+	return accepted, fnErr
+}
+
+// Replay hands every entry to fn, oldest first, and deletes it once fn
+// succeeds; it stops at the first error so entries are never applied out of
+// order. Corrupt files are set aside and skipped. It returns how many
+// entries were replayed.
+// The spool stays usable while fn runs: Stats answers and Append can add
+// entries, which the next replay picks up.
+func (s *Spool) Replay(fn func(*Entry) error) (int, error) {
+	s.replayMu.Lock()
+	defer s.replayMu.Unlock()
+
+	s.mu.Lock()
+	files, err := s.list()
+	s.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	replayed := 0
+	for _, f := range files {
+		path := filepath.Join(s.dir, f.name)
+
+		s.mu.Lock()
+		entry, err := readEntry(path)
+		if errors.Is(err, os.ErrNotExist) {
+			// Dropped by Append since it was listed
+			s.mu.Unlock()
+			continue
+		}
+		if errors.Is(err, ErrCorrupt) {
+			log.Printf("Setting aside corrupt spool file %s: %v", path, err)
+			err = os.Rename(path, path+corruptSuffix)
+			s.mu.Unlock()
+			if err != nil {
+				return replayed, err
+			}
+			continue
+		}
+		s.replaying = f.name
+		s.mu.Unlock()
+
+		if err == nil {
+			err = fn(entry)
+		}
+
+		s.mu.Lock()
+		s.replaying = ""
+		s.lastReplay = time.Now().UTC()
+		s.lastErr = err
+		if err == nil {
+			if err = os.Remove(path); err != nil {
+				err = fmt.Errorf("removing replayed spool entry: %w", err)
+			}
+		}
+		s.mu.Unlock()
+		if err != nil {
+			return replayed, err
+		}
+		replayed++
+	}
+	return replayed, nil
+}
+
+// Stats returns the spool's current backlog
+func (s *Spool) Stats() (Stats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := Stats{
+		Mode:     s.mode,
+		Dir:      s.dir,
+		MaxBytes: s.maxBytes,
+		MaxFiles: s.maxFiles,
+		Dropped:  s.dropped,
+	}
+	if !s.lastReplay.IsZero() {
+		lastReplay := s.lastReplay
+		stats.LastReplay = &lastReplay
+	}
+	if s.lastErr != nil {
+		stats.LastError = s.lastErr.Error()
+	}
+
+	files, err := s.list()
+	if err != nil {
+		return stats, err
+	}
+	stats.Entries = len(files)
+	for _, f := range files {
+		stats.Bytes += f.size
+	}
+	if len(files) > 0 {
+		oldest, newest := files[0].fetchedAt, files[len(files)-1].fetchedAt
+		stats.Oldest, stats.Newest = &oldest, &newest
+	}
+
+	corrupt, err := filepath.Glob(filepath.Join(s.dir, "*"+corruptSuffix))
+	if err != nil {
+		return stats, err
+	}
+	stats.Corrupt = len(corrupt)
+
+	droppedRuns, err := s.readDropped()
+	if err != nil {
+		return stats, err
+	}
+	stats.DroppedRuns = len(droppedRuns)
+	return stats, nil
+}
+
+// spoolFile is one entry on disk
+type spoolFile struct {
+	name      string
+	size      int64
+	fetchedAt time.Time
+}
+
+// list returns the entries on disk, oldest first
+func (s *Spool) list() ([]spoolFile, error) {
+	dirEntries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []spoolFile
+	for _, de := range dirEntries {
+		name := de.Name()
+		if de.IsDir() || !strings.HasSuffix(name, fileSuffix) {
+			continue
+		}
+		nanos, err := strconv.ParseInt(strings.TrimSuffix(name, fileSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := de.Info()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, spoolFile{name: name, size: info.Size(), fetchedAt: time.Unix(0, nanos).UTC()})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].name < files[j].name })
+	return files, nil
+}
+
+// readEntry decompresses a spool file and verifies its checksum
+func readEntry(path string) (*Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorrupt, err)
+	}
+	r := bufio.NewReader(zr)
+	header, err := r.ReadString('\n')
+	if err != nil || !strings.HasPrefix(header, headerPrefix) {
+		return nil, fmt.Errorf("%w: missing header", ErrCorrupt)
+	}
+	payload, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorrupt, err)
+	}
+
+	sum := sha256.Sum256(payload)
+	if want := strings.TrimSpace(strings.TrimPrefix(header, headerPrefix)); hex.EncodeToString(sum[:]) != want {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrCorrupt)
+	}
+
+	var entry Entry
+	if err := json.Unmarshal(payload, &entry); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorrupt, err)
+	}
+	return &entry, nil
+}
+
+// writeFileSync writes data to a temporary file, syncs it and renames it to
+// path, then syncs the directory so the rename survives a crash
+func writeFileSync(path string, data []byte) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	dir, err := os.Open(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
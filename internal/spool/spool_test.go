@@ -0,0 +1,190 @@
+package spool
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"osrs-price-api/internal/models"
+)
+
+var spoolStart = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func openTestSpool(t *testing.T, maxFiles int) *Spool {
+	t.Helper()
+	s, err := Open(&Config{Dir: t.TempDir(), MaxBytes: 1 << 30, MaxFiles: maxFiles}, models.GameModeMain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// appendEntries spools n snapshots fetched five minutes apart
+func appendEntries(t *testing.T, s *Spool, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		fetchedAt := spoolStart.Add(time.Duration(i) * 5 * time.Minute)
+		entry := &Entry{
+			Mode:      models.GameModeMain,
+			FetchedAt: fetchedAt,
+			Prices:    map[string]models.ItemPrice{"4151": {High: int64(1000 + i)}},
+			Run:       models.FetchRun{GameMode: models.GameModeMain, StartedAt: fetchedAt, Status: models.FetchStatusSuccess},
+			Error:     "database is down",
+		}
+		if err := s.Append(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestReplayInOrderStopsAtFirstError(t *testing.T) {
+	s := openTestSpool(t, 100)
+	appendEntries(t, s, 3)
+
+	var seen []time.Time
+	failing := errors.New("still down")
+	replayed, err := s.Replay(func(entry *Entry) error {
+		if len(seen) == 1 {
+			return failing
+		}
+		seen = append(seen, entry.FetchedAt)
+		return nil
+	})
+	if !errors.Is(err, failing) || replayed != 1 {
+		t.Fatalf("first replay = %d, %v; want 1, %v", replayed, err, failing)
+	}
+	if stats, _ := s.Stats(); stats.Entries != 2 || stats.LastError != failing.Error() {
+		t.Fatalf("after a failed replay: %d entries, last error %q", stats.Entries, stats.LastError)
+	}
+
+	replayed, err = s.Replay(func(entry *Entry) error {
+		seen = append(seen, entry.FetchedAt)
+		return nil
+	})
+	if err != nil || replayed != 2 {
+		t.Fatalf("second replay = %d, %v; want 2, nil", replayed, err)
+	}
+	for i, fetchedAt := range seen {
+		if want := spoolStart.Add(time.Duration(i) * 5 * time.Minute); !fetchedAt.Equal(want) {
+			t.Errorf("entry %d fetched at %s, want %s", i, fetchedAt, want)
+		}
+	}
+}
+
+func TestReplaySetsAsideCorruptFiles(t *testing.T) {
+	s := openTestSpool(t, 100)
+	appendEntries(t, s, 2)
+
+	files, _ := s.list()
+	if err := os.WriteFile(filepath.Join(s.dir, files[0].name), []byte("not gzip"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	replayed, err := s.Replay(func(entry *Entry) error { return nil })
+	if err != nil || replayed != 1 {
+		t.Fatalf("replay = %d, %v; want 1, nil", replayed, err)
+	}
+	if stats, _ := s.Stats(); stats.Entries != 0 || stats.Corrupt != 1 {
+		t.Errorf("after replay: %d entries, %d corrupt; want 0, 1", stats.Entries, stats.Corrupt)
+	}
+}
+
+func TestStatsDuringReplay(t *testing.T) {
+	s := openTestSpool(t, 100)
+	appendEntries(t, s, 2)
+
+	inside := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Replay(func(entry *Entry) error {
+			if entry.FetchedAt.Equal(spoolStart) {
+				close(inside)
+				<-release
+			}
+			return nil
+		})
+	}()
+
+	<-inside
+	statsDone := make(chan Stats)
+	go func() {
+		stats, _ := s.Stats()
+		statsDone <- stats
+	}()
+	select {
+	case stats := <-statsDone:
+		if stats.Entries != 2 {
+			t.Errorf("stats during replay: %d entries, want 2", stats.Entries)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Stats blocked while a replay callback was running")
+	}
+	close(release)
+	<-done
+}
+
+func TestDroppedEntriesKeepTheirFetchRuns(t *testing.T) {
+	s := openTestSpool(t, 2)
+	appendEntries(t, s, 4)
+
+	stats, err := s.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Entries != 2 || stats.Dropped != 2 || stats.DroppedRuns != 2 {
+		t.Fatalf("stats = %d entries, %d dropped, %d dropped runs; want 2, 2, 2", stats.Entries, stats.Dropped, stats.DroppedRuns)
+	}
+
+	failing := errors.New("still down")
+	if n, err := s.ReplayDropped(func(run *models.FetchRun) error { return failing }); n != 0 || !errors.Is(err, failing) {
+		t.Fatalf("ReplayDropped = %d, %v; want 0, %v", n, err, failing)
+	}
+
+	var runs []models.FetchRun
+	n, err := s.ReplayDropped(func(run *models.FetchRun) error {
+		runs = append(runs, *run)
+		return nil
+	})
+	if err != nil || n != 2 {
+		t.Fatalf("ReplayDropped = %d, %v; want 2, nil", n, err)
+	}
+	for i, run := range runs {
+		if run.Status != models.FetchStatusFailed || run.Error == nil {
+			t.Errorf("dropped run %d = %+v, want a failed run with an error", i, run)
+		}
+		if want := spoolStart.Add(time.Duration(i) * 5 * time.Minute); !run.StartedAt.Equal(want) {
+			t.Errorf("dropped run %d started at %s, want %s (the oldest entries)", i, run.StartedAt, want)
+		}
+	}
+
+	if stats, _ := s.Stats(); stats.DroppedRuns != 0 {
+		t.Errorf("%d dropped runs left after recording them", stats.DroppedRuns)
+	}
+}
+
+func TestAppendDoesNotDropEntryBeingReplayed(t *testing.T) {
+	s := openTestSpool(t, 1)
+	appendEntries(t, s, 1)
+
+	var appendErr error
+	replayed, err := s.Replay(func(entry *Entry) error {
+		// Over the cap while the only older entry is being replayed
+		next := *entry
+		next.FetchedAt = entry.FetchedAt.Add(time.Hour)
+		appendErr = s.Append(&next)
+		return nil
+	})
+	if appendErr != nil {
+		t.Fatal(appendErr)
+	}
+	if err != nil || replayed != 1 {
+		t.Fatalf("replay = %d, %v; want 1, nil", replayed, err)
+	}
+	if stats, _ := s.Stats(); stats.Entries != 1 || stats.Dropped != 0 {
+		t.Errorf("after replay: %d entries, %d dropped; want 1, 0", stats.Entries, stats.Dropped)
+	}
+}
@@ -0,0 +1,81 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"osrs-price-api/internal/database"
+	"osrs-price-api/internal/models"
+	"osrs-price-api/internal/osrs"
+)
+
+// maxCatchUpWindows limits how many missed windows are fetched in a single run
+// so a long outage doesn't turn into a burst of requests against the Wiki
+const maxCatchUpWindows = 12
+
+// AveragePriceFetcher fetches 5-minute or 1-hour average prices and volumes on each run
+type AveragePriceFetcher struct {
+	source     osrs.PriceSource
+	repository *database.Repository
+	mode       string
+	timestep   string
+	window     time.Duration
+}
+
+// NewAveragePriceFetcher creates a new average price worker for a game mode and
+// timestep (models.Timestep5m or models.Timestep1h)
+func NewAveragePriceFetcher(source osrs.PriceSource, repo *database.Repository, mode, timestep string) *AveragePriceFetcher {
+	window := 5 * time.Minute
+	if timestep == models.Timestep1h {
+		window = time.Hour
+	}
+
+	return &AveragePriceFetcher{
+		source:     source,
+		repository: repo,
+		mode:       mode,
+		timestep:   timestep,
+		window:     window,
+	}
+}
+
+// Run fetches every closed window since the last stored one, using the
+// Wiki's timestamp parameter to fill gaps left by restarts
+func (af *AveragePriceFetcher) Run(ctx context.Context) error {
+	// The most recent window that has fully closed
+	latest := time.Now().UTC().Truncate(af.window).Add(-af.window)
+	start := latest
+
+	lastStored, err := af.repository.GetLatestAverageTimestamp(ctx, af.mode, af.timestep)
+	if err != nil {
+		log.Printf("Error reading last %s %s window: %v", af.mode, af.timestep, err)
+	} else if !lastStored.IsZero() {
+		start = lastStored.Add(af.window)
+		earliest := latest.Add(-time.Duration(maxCatchUpWindows-1) * af.window)
+		if start.Before(earliest) {
+			start = earliest
+		}
+	}
+
+	for ts := start; !ts.After(latest); ts = ts.Add(af.window) {
+		averages, err := af.source.GetAveragePrices(ctx, af.timestep, ts.Unix())
+		if err != nil {
+			return fmt.Errorf("fetching %s %s averages for %s: %w", af.mode, af.timestep, ts.Format(time.RFC3339), err)
+		}
+
+		// The Wiki publishes a window shortly after it closes; try again next run
+		if len(averages.Data) == 0 {
+			return nil
+		}
+
+		saved, err := af.repository.SavePriceAverages(ctx, af.mode, af.timestep, averages)
+		if err != nil {
+			return fmt.Errorf("saving %s %s averages to database: %w", af.mode, af.timestep, err)
+		}
+
+		log.Printf("Saved %d %s %s averages for window %s", saved, af.mode, af.timestep, ts.Format(time.RFC3339))
+	}
+	return nil
+}
@@ -1,105 +1,122 @@
 package worker
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"osrs-price-api/internal/database"
+	"osrs-price-api/internal/models"
 )
 
-// CleanupWorker handles database cleanup and aggregation
+// cleanupLogRetention is how long cleanup_logs and fetch_runs rows are kept
+const cleanupLogRetention = 90 * 24 * time.Hour
+
+// partitionLookahead is how far ahead the cleanup job creates daily
+// price_history partitions
+const partitionLookahead = 7 * 24 * time.Hour
+
+// logTimeout bounds writing a run's log row, which happens even if the run
+// itself timed out or was cancelled
+const logTimeout = 10 * time.Second
+
+// cleanupTier is one storage tier and how its old rows are deleted
+type cleanupTier struct {
+	name        string
+	rolledUpTo  []string // Tiers fed from this one; rows are kept until all of them rolled up
+	deleteOlder func(*database.Repository, context.Context, time.Time) (int64, error)
+	averages    string // Average price windows that share this tier's retention
+}
+
+var cleanupTiers = []cleanupTier{
+	{models.ResolutionRaw, []string{models.ResolutionHourly}, (*database.Repository).DeleteOldPriceHistory, models.Timestep5m},
+	{models.ResolutionHourly, []string{models.ResolutionDaily}, (*database.Repository).DeleteOldHourlyData, models.Timestep1h},
+	{models.ResolutionDaily, []string{models.ResolutionWeekly, models.ResolutionMonthly}, (*database.Repository).DeleteOldDailyData, ""},
+	{models.ResolutionWeekly, nil, (*database.Repository).DeleteOldWeeklyData, ""},
+	{models.ResolutionMonthly, nil, (*database.Repository).DeleteOldMonthlyData, ""},
+}
+
+// CleanupWorker deletes data that has aged out of each tier
+// Retention is read from cleanup_config on every run and each run is recorded
+// in cleanup_logs. Aggregation is done continuously by the RollupWorker;
+// cleanup never deletes rows that haven't been rolled up into every tier fed
+// from them yet.
 type CleanupWorker struct {
 	repository *database.Repository
-	interval   time.Duration
-	stopChan   chan bool
 }
 
 // NewCleanupWorker creates a new cleanup worker
-func NewCleanupWorker(repo *database.Repository, interval time.Duration) *CleanupWorker {
+func NewCleanupWorker(repo *database.Repository) *CleanupWorker {
 	return &CleanupWorker{
 		repository: repo,
-		interval:   interval,
-		stopChan:   make(chan bool),
 	}
 }
 
-// Start begins the periodic cleanup
-func (cw *CleanupWorker) Start() {
-	log.Printf("Starting cleanup worker (interval: %s)", cw.interval)
-
-	// Run immediately on start
-	cw.runCleanup()
-
-	// Then continue on interval
-	ticker := time.NewTicker(cw.interval)
-	go func() {
-		for {
-			select {
-			case <-ticker.C:
-				cw.runCleanup()
-			case <-cw.stopChan:
-				ticker.Stop()
-				log.Println("Cleanup worker stopped")
-				return
-			}
-		}
-	}()
-}
-
-// Stop stops the cleanup worker
-func (cw *CleanupWorker) Stop() {
-	cw.stopChan <- true
-}
-
-func (cw *CleanupWorker) runCleanup() {
-	log.Println("Running database cleanup and aggregation...")
+// Run deletes every enabled tier's data past its retention and records the run
+// in cleanup_logs; it fails if any tier could not be cleaned
+func (cw *CleanupWorker) Run(ctx context.Context) error {
+	log.Println("Running database cleanup...")
+	started := time.Now().UTC()
 
-	// Step 1: Aggregate data from 8-90 days ago into hourly buckets
-	hourlyStart := time.Now().UTC().Add(-90 * 24 * time.Hour)
-	hourlyEnd := time.Now().UTC().Add(-8 * 24 * time.Hour)
-	
-	hourlyAggregated, err := cw.repository.AggregateToHourly(hourlyStart, hourlyEnd)
+	entry := &models.CleanupLog{Job: models.CleanupJobCleanup, Status: models.CleanupStatusSuccess}
+	configs, err := cw.repository.GetCleanupConfigs(ctx)
 	if err != nil {
-		log.Printf("Error aggregating hourly data: %v", err)
-	} else if hourlyAggregated > 0 {
-		log.Printf("Aggregated %d records into hourly buckets", hourlyAggregated)
+		log.Printf("Error loading cleanup config: %v", err)
+		entry.Tiers = append(entry.Tiers, models.CleanupTierResult{Error: err.Error()})
 	}
 
-	// Step 2: Aggregate hourly data older than 90 days into daily buckets
-	dailyStart := time.Now().UTC().Add(-5 * 365 * 24 * time.Hour) // 5 years back
-	dailyEnd := time.Now().UTC().Add(-90 * 24 * time.Hour)
-	
-	dailyAggregated, err := cw.repository.AggregateToDaily(dailyStart, dailyEnd)
-	if err != nil {
-		log.Printf("Error aggregating daily data: %v", err)
-	} else if dailyAggregated > 0 {
-		log.Printf("Aggregated %d records into daily buckets", dailyAggregated)
+	// Create the raw partitions for the coming days before they are needed
+	if created, err := cw.repository.EnsurePriceHistoryPartitions(ctx, started, started.Add(partitionLookahead)); err != nil {
+		log.Printf("Error creating price history partitions: %v", err)
+		entry.Tiers = append(entry.Tiers, models.CleanupTierResult{Error: "creating partitions: " + err.Error()})
+	} else if created > 0 {
+		log.Printf("Created %d price history partitions", created)
 	}
 
-	// Step 3: Delete raw 5-minute data older than 8 days (now that it's aggregated)
-	rawDataCutoff := time.Now().UTC().Add(-8 * 24 * time.Hour)
-	deleted, err := cw.repository.DeleteOldPriceHistory(rawDataCutoff)
-	if err != nil {
-		log.Printf("Error during cleanup: %v", err)
-		return
-	}
+	for _, config := range configs {
+		tier, ok := findCleanupTier(config.Tier)
+		if !ok {
+			log.Printf("Ignoring cleanup config for unknown tier %q", config.Tier)
+			continue
+		}
+		if !config.Enabled || config.RetentionDays <= 0 {
+			continue
+		}
 
-	if deleted > 0 {
-		log.Printf("Deleted %d old raw records (older than 8 days)", deleted)
+		result := cw.cleanTier(ctx, tier, started.AddDate(0, 0, -config.RetentionDays))
+		if result.Error != "" {
+			log.Printf("Error cleaning up %s data: %s", tier.name, result.Error)
+		} else {
+			if result.Deleted > 0 {
+				log.Printf("Deleted %d old %s records (older than %d days)", result.Deleted, tier.name, config.RetentionDays)
+			}
+			if err := cw.repository.RecordCleanup(ctx, tier.name, started, result.Deleted); err != nil {
+				log.Printf("Error recording %s cleanup: %v", tier.name, err)
+			}
+		}
+		entry.RecordsDeleted += result.Deleted
+		entry.Tiers = append(entry.Tiers, result)
 	}
 
-	// Step 4: Delete hourly data older than 90 days (now aggregated to daily)
-	hourlyDeleteCutoff := time.Now().UTC().Add(-90 * 24 * time.Hour)
-	deletedHourly, err := cw.repository.DeleteOldHourlyData(hourlyDeleteCutoff)
-	if err != nil {
-		log.Printf("Error deleting old hourly data: %v", err)
-	} else if deletedHourly > 0 {
-		log.Printf("Deleted %d old hourly records (older than 90 days)", deletedHourly)
+	if deleted, err := cw.repository.DeleteOldCleanupLogs(ctx, started.Add(-cleanupLogRetention)); err != nil {
+		log.Printf("Error deleting old cleanup logs: %v", err)
+	} else if deleted > 0 {
+		log.Printf("Deleted %d old cleanup log entries", deleted)
+	}
+	if deleted, err := cw.repository.DeleteOldFetchRuns(ctx, started.Add(-cleanupLogRetention)); err != nil {
+		log.Printf("Error deleting old fetch runs: %v", err)
+	} else if deleted > 0 {
+		log.Printf("Deleted %d old fetch run entries", deleted)
 	}
 
+	entry.DurationMs = int(time.Since(started).Milliseconds())
+	runErr := saveRunLog(ctx, cw.repository, entry)
+
 	// Get database stats
-	stats, err := cw.repository.GetDatabaseStats()
+	stats, err := cw.repository.GetDatabaseStats(ctx)
 	if err != nil {
 		log.Printf("Error getting database stats: %v", err)
 	} else {
@@ -108,6 +125,84 @@ func (cw *CleanupWorker) runCleanup() {
 			formatBytes(stats.EstimatedSize),
 			stats.OldestRecord.Format("2006-01-02"))
 	}
+	return runErr
+}
+
+func findCleanupTier(name string) (cleanupTier, bool) {
+	for _, tier := range cleanupTiers {
+		if tier.name == name {
+			return tier, true
+		}
+	}
+	return cleanupTier{}, false
+}
+
+// cleanTier deletes a tier's rows older than cutoff, limited to what every
+// tier fed from it has rolled up
+func (cw *CleanupWorker) cleanTier(ctx context.Context, tier cleanupTier, cutoff time.Time) models.CleanupTierResult {
+	result := models.CleanupTierResult{Tier: tier.name}
+
+	rowsCutoff := cutoff
+	for _, next := range tier.rolledUpTo {
+		watermark, err := cw.repository.GetRollupWatermark(ctx, next)
+		if err != nil {
+			result.Error = fmt.Sprintf("reading %s rollup watermark: %v", next, err)
+			return result
+		}
+		if watermark.Before(rowsCutoff) {
+			rowsCutoff = watermark
+		}
+	}
+	result.Cutoff = &rowsCutoff
+
+	deleted, err := tier.deleteOlder(cw.repository, ctx, rowsCutoff)
+	result.Deleted += deleted
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	// Average windows come from the Wiki rather than a rollup, so they age out on time
+	if tier.averages != "" {
+		deleted, err := cw.repository.DeleteOldPriceAverages(ctx, tier.averages, cutoff)
+		result.Deleted += deleted
+		if err != nil {
+			result.Error = fmt.Sprintf("deleting %s averages: %v", tier.averages, err)
+		}
+	}
+	return result
+}
+
+// saveRunLog records a finished run in cleanup_logs, marking it failed if any
+// tier reported an error, and returns those errors combined
+// The write gets its own deadline so runs that timed out are still recorded.
+func saveRunLog(parent context.Context, repo *database.Repository, entry *models.CleanupLog) error {
+	var errs []string
+	for _, tier := range entry.Tiers {
+		switch {
+		case tier.Error == "":
+		case tier.Tier == "":
+			errs = append(errs, tier.Error)
+		default:
+			errs = append(errs, tier.Tier+": "+tier.Error)
+		}
+	}
+	if len(errs) > 0 {
+		message := strings.Join(errs, "; ")
+		entry.Status = models.CleanupStatusFailed
+		entry.ErrorMessage = &message
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(parent), logTimeout)
+	defer cancel()
+	if err := repo.SaveCleanupLog(ctx, entry); err != nil {
+		log.Printf("Error saving %s log: %v", entry.Job, err)
+	}
+
+	if entry.ErrorMessage != nil {
+		return errors.New(*entry.ErrorMessage)
+	}
+	return nil
 }
 
 func formatBytes(bytes int64) string {
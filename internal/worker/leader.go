@@ -0,0 +1,145 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"log"
+	"sync"
+	"time"
+)
+
+// leaderLockID is the Postgres advisory lock key held by the instance that
+// runs background jobs (migrations use 7_245_301, the TimescaleDB conversion
+// 7_245_303)
+const leaderLockID = 7_245_302
+
+// LeaderElector elects one instance among replicas sharing a database to run
+// background jobs
+// The leader holds a session-level advisory lock on a dedicated connection.
+// Postgres releases the lock when that session ends, so if the leader dies
+// another instance takes over on its next attempt. The leader checks its
+// session on the same interval and steps down as soon as it is lost.
+type LeaderElector struct {
+	db       *sql.DB
+	interval time.Duration
+
+	mu      sync.Mutex
+	conn    *sql.Conn
+	ctx     context.Context // Ends when leadership is lost
+	cancel  context.CancelFunc
+	leading bool
+
+	stopChan chan struct{}
+	done     chan struct{}
+}
+
+// NewLeaderElector creates an elector that tries to acquire or verify
+// leadership every interval
+func NewLeaderElector(db *sql.DB, interval time.Duration) *LeaderElector {
+	return &LeaderElector{
+		db:       db,
+		interval: interval,
+		stopChan: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start makes a first attempt right away, so a lone instance is leader before
+// its jobs start, then keeps campaigning in the background
+func (le *LeaderElector) Start() {
+	le.campaign()
+
+	ticker := time.NewTicker(le.interval)
+	go func() {
+		defer close(le.done)
+		for {
+			select {
+			case <-ticker.C:
+				le.campaign()
+			case <-le.stopChan:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops campaigning and releases leadership
+func (le *LeaderElector) Stop() {
+	close(le.stopChan)
+	<-le.done
+
+	le.mu.Lock()
+	defer le.mu.Unlock()
+	if le.leading {
+		le.stepDown()
+		log.Println("Released leadership")
+	}
+}
+
+// Leading returns whether this instance is the leader, and a context that is
+// cancelled when it stops being the leader
+func (le *LeaderElector) Leading() (context.Context, bool) {
+	le.mu.Lock()
+	defer le.mu.Unlock()
+	return le.ctx, le.leading
+}
+
+// campaign verifies the session of a leader, or tries to become leader
+// Only the campaign loop (and Stop, after it ended) changes conn, so the
+// queries run without holding le.mu.
+func (le *LeaderElector) campaign() {
+	ctx, cancel := context.WithTimeout(context.Background(), le.interval)
+	defer cancel()
+
+	if le.conn != nil {
+		if _, err := le.conn.ExecContext(ctx, "SELECT 1"); err != nil {
+			log.Printf("Lost leadership: %v", err)
+			le.mu.Lock()
+			le.stepDown()
+			le.mu.Unlock()
+		}
+		return
+	}
+
+	conn, err := le.db.Conn(ctx)
+	if err != nil {
+		log.Printf("Leader election: %v", err)
+		return
+	}
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", leaderLockID).Scan(&acquired); err != nil {
+		log.Printf("Leader election: %v", err)
+		closeSession(conn)
+		return
+	}
+	if !acquired {
+		conn.Close() // Another instance leads; the session holds nothing
+		return
+	}
+
+	le.mu.Lock()
+	le.conn = conn
+	le.ctx, le.cancel = context.WithCancel(context.Background())
+	le.leading = true
+	le.mu.Unlock()
+	log.Println("Elected leader: this instance runs background jobs")
+}
+
+// stepDown cancels in-flight jobs and closes the lock's session; the caller
+// holds le.mu
+func (le *LeaderElector) stepDown() {
+	le.cancel()
+	closeSession(le.conn)
+	le.conn = nil
+	le.leading = false
+}
+
+// closeSession closes a connection's database session instead of returning it
+// to the pool, so a session-level lock can't outlive it
+func closeSession(conn *sql.Conn) {
+	// Reporting the connection as bad makes database/sql discard it
+	conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+	conn.Close()
+}
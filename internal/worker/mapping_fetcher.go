@@ -0,0 +1,52 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"osrs-price-api/internal/cache"
+	"osrs-price-api/internal/database"
+	"osrs-price-api/internal/models"
+	"osrs-price-api/internal/osrs"
+)
+
+// MappingFetcher refreshes the item catalog from the OSRS Wiki on each run
+type MappingFetcher struct {
+	source     osrs.PriceSource
+	cache      *cache.PriceCache
+	repository *database.Repository
+}
+
+// NewMappingFetcher creates a new item mapping worker
+func NewMappingFetcher(source osrs.PriceSource, priceCache *cache.PriceCache, repo *database.Repository) *MappingFetcher {
+	return &MappingFetcher{
+		source:     source,
+		cache:      priceCache,
+		repository: repo,
+	}
+}
+
+// Run fetches the item catalog, saves it and publishes it to the cache
+func (mf *MappingFetcher) Run(ctx context.Context) error {
+	log.Println("Fetching item mapping from OSRS Wiki API...")
+
+	items, err := mf.source.GetMapping(ctx)
+	if err != nil {
+		return fmt.Errorf("fetching item mapping: %w", err)
+	}
+
+	if err := mf.repository.SaveItemMappings(ctx, items); err != nil {
+		return fmt.Errorf("saving item mapping to database: %w", err)
+	}
+
+	// Publish the refreshed catalog to the API and price fetchers
+	mapping := make(map[int]models.ItemMapping, len(items))
+	for _, item := range items {
+		mapping[item.ID] = item
+	}
+	mf.cache.SetItemMappings(mapping)
+
+	log.Printf("Successfully saved %d items to catalog", len(items))
+	return nil
+}
@@ -1,74 +1,284 @@
 package worker
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"time"
 
+	"osrs-price-api/internal/cache"
 	"osrs-price-api/internal/database"
+	"osrs-price-api/internal/models"
 	"osrs-price-api/internal/osrs"
+	"osrs-price-api/internal/spool"
 )
 
-// PriceFetcher periodically fetches and stores price data
+// PriceFetcher fetches and stores price data for one game mode on each run
+// It is the only component that requests latest prices from the Wiki: each
+// snapshot is pushed into the cache for the API handlers and recorded in the
+// database. Only items whose trade times changed since they were last stored
+// are saved, plus a keyframe of every item once per models.KeyframeInterval;
+// reads carry the last stored prices forward.
+// Snapshots that can't be saved are written to the spool and replayed, oldest
+// first, before the next snapshot is saved.
+// Runs must not overlap, which the scheduler guarantees.
 type PriceFetcher struct {
-	client     *osrs.Client
+	source     osrs.PriceSource
+	cache      *cache.PriceCache
 	repository *database.Repository
-	interval   time.Duration
-	stopChan   chan bool
+	spool      *spool.Spool
+	mode       string
+
+	stored   map[string]storedTrade // Last stored state per item, loaded on the first run
+	lastHash string                 // Content hash of the previous snapshot
+}
+
+// storedTrade is what was last stored for an item
+type storedTrade struct {
+	highTime, lowTime int64
+	at                time.Time
 }
 
-// NewPriceFetcher creates a new price fetcher worker
-func NewPriceFetcher(client *osrs.Client, repo *database.Repository, interval time.Duration) *PriceFetcher {
+// NewPriceFetcher creates a new price fetcher worker for a game mode
+func NewPriceFetcher(source osrs.PriceSource, priceCache *cache.PriceCache, repo *database.Repository, snapshotSpool *spool.Spool, mode string) *PriceFetcher {
 	return &PriceFetcher{
-		client:     client,
+		source:     source,
+		cache:      priceCache,
 		repository: repo,
-		interval:   interval,
-		stopChan:   make(chan bool),
+		spool:      snapshotSpool,
+		mode:       mode,
 	}
 }
 
-// Start begins the periodic price fetching
-func (pf *PriceFetcher) Start() {
-	log.Printf("Starting price fetcher worker (interval: %s)", pf.interval)
-	
-	// Fetch immediately on start
-	pf.fetchAndStore()
-
-	// Then continue on interval
-	ticker := time.NewTicker(pf.interval)
-	go func() {
-		for {
-			select {
-			case <-ticker.C:
-				pf.fetchAndStore()
-			case <-pf.stopChan:
-				ticker.Stop()
-				log.Println("Price fetcher worker stopped")
-				return
-			}
-		}
-	}()
+// Run fetches the latest prices, publishes them to the cache and saves the
+// items that changed; every attempt is recorded in fetch_runs
+func (pf *PriceFetcher) Run(ctx context.Context) error {
+	run := &models.FetchRun{
+		GameMode:  pf.mode,
+		Source:    pf.source.Name(),
+		StartedAt: time.Now().UTC(),
+		Status:    models.FetchStatusSuccess,
+	}
+
+	err := pf.fetch(ctx, run)
+	if err == nil || run.Status == models.FetchStatusReplayed {
+		// A spooled run is recorded when it is replayed
+		return err
+	}
+
+	// A successful run is saved with its snapshot; record the failure on its
+	// own, even if the run itself timed out or was cancelled
+	message := err.Error()
+	run.Status = models.FetchStatusFailed
+	run.Error = &message
+	run.FinishedAt = time.Now().UTC()
+
+	saveCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), logTimeout)
+	defer cancel()
+	if saveErr := pf.repository.SaveFetchRun(saveCtx, run); saveErr != nil {
+		log.Printf("Error saving %s fetch run: %v", pf.mode, saveErr)
+	}
+	return err
+}
+
+// fetch does the work of one run, filling in run as it goes
+func (pf *PriceFetcher) fetch(ctx context.Context, run *models.FetchRun) error {
+	log.Printf("Fetching latest %s prices from OSRS Wiki API...", pf.mode)
+
+	var stats osrs.ResponseStats
+	prices, err := pf.source.GetLatestPrices(osrs.WithResponseStats(ctx, &stats))
+	run.Bytes = stats.Bytes
+	if err != nil {
+		return fmt.Errorf("fetching prices: %w", err)
+	}
+	run.Items = len(prices)
+	fetchedAt := time.Now().UTC()
+
+	// Publish the snapshot to the API before saving, so a slow or failing
+	// database doesn't hold back fresh prices
+	models.ApplyItemNames(prices, pf.itemMappings(ctx))
+	pf.cache.SetAll(pf.mode, cache.Snapshot{Prices: prices, FetchedAt: fetchedAt})
+	run.FinishedAt = time.Now().UTC()
+
+	// Earlier snapshots still waiting in the spool go first, so history is
+	// always written in order
+	if err := pf.replaySpool(ctx); err != nil {
+		return pf.spoolSnapshot(prices, fetchedAt, run, fmt.Errorf("replaying spooled snapshots: %w", err))
+	}
+
+	snapshot, err := pf.store(ctx, prices, fetchedAt, run)
+	if err != nil {
+		return pf.spoolSnapshot(prices, fetchedAt, run, err)
+	}
+	pf.cache.SetSnapshotID(pf.mode, fetchedAt, snapshot.ID)
+	return nil
 }
 
-// Stop stops the price fetcher
-func (pf *PriceFetcher) Stop() {
-	pf.stopChan <- true
+// store saves the items of a snapshot that changed since they were last
+// stored, along with its fetch run
+func (pf *PriceFetcher) store(ctx context.Context, prices map[string]models.ItemPrice, fetchedAt time.Time, run *models.FetchRun) (*models.PriceSnapshot, error) {
+	if err := pf.loadStored(ctx); err != nil {
+		return nil, fmt.Errorf("loading last stored prices: %w", err)
+	}
+
+	// The Wiki refreshes /latest every few minutes, so the snapshot belongs to
+	// the window it was fetched in
+	snapshot := &models.PriceSnapshot{
+		GameMode:     pf.mode,
+		SnapshotTime: fetchedAt.Truncate(models.SnapshotInterval),
+		FetchedAt:    fetchedAt,
+		ContentHash:  models.HashPrices(prices),
+		ItemsTotal:   len(prices),
+	}
+	changed := pf.changedPrices(prices, snapshot)
+	snapshot.ItemsChanged = len(changed)
+
+	if err := pf.repository.SavePriceSnapshot(ctx, snapshot, changed, run); err != nil {
+		return nil, fmt.Errorf("saving prices to database: %w", err)
+	}
+
+	for id, price := range changed {
+		pf.stored[id] = storedTrade{highTime: price.HighTime, lowTime: price.LowTime, at: snapshot.SnapshotTime}
+	}
+	pf.lastHash = snapshot.ContentHash
+
+	log.Printf("Saved snapshot %d: %d of %d items changed", snapshot.ID, len(changed), len(prices))
+	return snapshot, nil
 }
 
-func (pf *PriceFetcher) fetchAndStore() {
-	log.Println("Fetching latest prices from OSRS Wiki API...")
-	
-	prices, err := pf.client.GetLatestPrices()
+// replaySpool records the fetches the spool had to drop, then saves the
+// spooled snapshots, oldest first, stopping at the first one that still
+// can't be saved
+// Saving a snapshot rewinds the rollup watermarks to it, so the hours it
+// lands in are aggregated again even if the rollup already closed them.
+func (pf *PriceFetcher) replaySpool(ctx context.Context) error {
+	if pf.spool == nil {
+		return nil
+	}
+
+	recorded, err := pf.spool.ReplayDropped(func(run *models.FetchRun) error {
+		return pf.repository.SaveFetchRun(ctx, run)
+	})
+	if recorded > 0 {
+		log.Printf("Recorded %d %s fetches dropped from the spool", recorded, pf.mode)
+	}
 	if err != nil {
-		log.Printf("Error fetching prices: %v", err)
-		return
+		return fmt.Errorf("recording dropped fetches: %w", err)
 	}
 
-	log.Printf("Fetched %d item prices, saving to database...", len(prices))
-	
-	if err := pf.repository.SavePriceHistory(prices); err != nil {
-		log.Printf("Error saving prices to database: %v", err)
-		return
+	replayed, err := pf.spool.Replay(func(entry *spool.Entry) error {
+		run := entry.Run
+		run.Status = models.FetchStatusReplayed
+		run.Error = &entry.Error
+		_, err := pf.store(ctx, entry.Prices, entry.FetchedAt, &run)
+		return err
+	})
+	if replayed > 0 {
+		log.Printf("Replayed %d spooled %s snapshots", replayed, pf.mode)
 	}
+	return err
+}
 
-	log.Println("Successfully saved prices to database")
+// spoolSnapshot writes a snapshot that couldn't be saved to the spool and
+// returns the save error
+// Once spooled, run is marked as replayed so it isn't also recorded as failed.
+func (pf *PriceFetcher) spoolSnapshot(prices map[string]models.ItemPrice, fetchedAt time.Time, run *models.FetchRun, err error) error {
+	if pf.spool == nil {
+		return err
+	}
+
+	entry := &spool.Entry{
+		Mode:      pf.mode,
+		FetchedAt: fetchedAt,
+		Prices:    prices,
+		Run:       *run,
+		Error:     err.Error(),
+	}
+	if spoolErr := pf.spool.Append(entry); spoolErr != nil {
+		return fmt.Errorf("%w (spooling snapshot also failed: %v)", err, spoolErr)
+	}
+
+	log.Printf("Spooled %s snapshot fetched at %s for replay", pf.mode, fetchedAt.Format(time.RFC3339))
+	run.Status = models.FetchStatusReplayed
+	return fmt.Errorf("%w (snapshot spooled for replay)", err)
+}
+
+// loadStored reads the last stored state of every item once, so a restart
+// doesn't store everything again
+func (pf *PriceFetcher) loadStored(ctx context.Context) error {
+	if pf.stored != nil {
+		return nil
+	}
+
+	records, err := pf.repository.GetLatestStoredPrices(ctx, pf.mode)
+	if err != nil {
+		return err
+	}
+	last, err := pf.repository.GetLastPriceSnapshot(ctx, pf.mode)
+	if err != nil {
+		return err
+	}
+
+	stored := make(map[string]storedTrade, len(records))
+	for _, record := range records {
+		stored[fmt.Sprintf("%d", record.ItemID)] = storedTrade{
+			highTime: record.HighTime,
+			lowTime:  record.LowTime,
+			at:       record.Timestamp.UTC(),
+		}
+	}
+	pf.stored = stored
+	if last != nil {
+		pf.lastHash = last.ContentHash
+	}
+	return nil
+}
+
+// changedPrices returns the items of a snapshot that need storing
+// A snapshot identical to the previous one stores nothing, not even keyframes
+// that came due: they are written with the next snapshot that differs.
+func (pf *PriceFetcher) changedPrices(prices map[string]models.ItemPrice, snapshot *models.PriceSnapshot) map[string]models.ItemPrice {
+	changed := make(map[string]models.ItemPrice)
+	if snapshot.ContentHash == pf.lastHash {
+		log.Printf("Fetched %d item prices, unchanged since the last snapshot", len(prices))
+		return changed
+	}
+
+	for id, price := range prices {
+		if pf.needsStoring(id, price, snapshot.SnapshotTime) {
+			changed[id] = price
+		}
+	}
+	return changed
+}
+
+// needsStoring reports whether an item traded since it was last stored, or
+// is due for a keyframe
+func (pf *PriceFetcher) needsStoring(id string, price models.ItemPrice, snapshotTime time.Time) bool {
+	last, ok := pf.stored[id]
+	if !ok {
+		return true
+	}
+	if price.HighTime != last.highTime || price.LowTime != last.lowTime {
+		return true
+	}
+	return !snapshotTime.Before(last.at.Add(models.KeyframeInterval))
+}
+
+// itemMappings returns the item catalog pushed by the mapping worker,
+// loading it from the database if the worker hasn't run yet
+func (pf *PriceFetcher) itemMappings(ctx context.Context) map[int]models.ItemMapping {
+	if mapping, found := pf.cache.GetItemMappings(); found {
+		return mapping
+	}
+
+	mapping, err := pf.repository.GetItemMappings(ctx)
+	if err != nil {
+		log.Printf("Error loading item mapping: %v", err)
+		return nil
+	}
+	if len(mapping) > 0 {
+		pf.cache.SetItemMappings(mapping)
+	}
+	return mapping
 }
\ No newline at end of file
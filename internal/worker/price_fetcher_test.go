@@ -0,0 +1,28 @@
+package worker
+
+import (
+	"testing"
+	"time"
+
+	"osrs-price-api/internal/models"
+)
+
+func TestChangedPricesSkipsUnchangedSnapshot(t *testing.T) {
+	snapshotTime := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	prices := map[string]models.ItemPrice{
+		"4151":  {High: 1_500_000, HighTime: 100, Low: 1_450_000, LowTime: 90},
+		"11840": {High: 3_000, HighTime: 80, Low: 2_900, LowTime: 70},
+	}
+	snapshot := &models.PriceSnapshot{SnapshotTime: snapshotTime, ContentHash: models.HashPrices(prices)}
+
+	// Neither item has been stored, and both would be due for a keyframe
+	pf := &PriceFetcher{stored: map[string]storedTrade{}, lastHash: snapshot.ContentHash}
+	if changed := pf.changedPrices(prices, snapshot); len(changed) != 0 {
+		t.Errorf("unchanged snapshot stores %d rows, want 0", len(changed))
+	}
+
+	pf.lastHash = "previous"
+	if changed := pf.changedPrices(prices, snapshot); len(changed) != len(prices) {
+		t.Errorf("changed snapshot stores %d rows, want %d", len(changed), len(prices))
+	}
+}
@@ -0,0 +1,138 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"osrs-price-api/internal/database"
+	"osrs-price-api/internal/models"
+)
+
+// rollupGrace is how long after a bucket ends before it is closed, so the
+// last price fetch of the bucket has been saved
+const rollupGrace = 5 * time.Minute
+
+// rollupTier is one aggregate tier and the tier it is rolled up from
+type rollupTier struct {
+	name      string
+	source    string
+	chunk     time.Duration // Largest range aggregated by one statement while catching up
+	aggregate func(*database.Repository, context.Context, time.Time, time.Time) (int64, error)
+}
+
+// rollupTiers are processed in order; each tier only rolls up buckets its
+// source tier has already closed, so sources come before the tiers they feed
+var rollupTiers = []rollupTier{
+	{models.ResolutionHourly, models.ResolutionRaw, 24 * time.Hour, (*database.Repository).AggregateToHourly},
+	{models.ResolutionDaily, models.ResolutionHourly, 30 * 24 * time.Hour, (*database.Repository).AggregateToDaily},
+	{models.ResolutionWeekly, models.ResolutionDaily, 52 * 7 * 24 * time.Hour, (*database.Repository).AggregateToWeekly},
+	{models.ResolutionMonthly, models.ResolutionDaily, 365 * 24 * time.Hour, (*database.Repository).AggregateToMonthly},
+}
+
+// RollupWorker continuously closes hourly, daily, weekly and monthly buckets
+// Each bucket is aggregated shortly after it ends (hours 5 minutes after the
+// hour, the other tiers once their last day is closed), so every resolution
+// covers recent periods too. Progress is kept as a watermark per tier, so a
+// restart resumes where the last run stopped.
+type RollupWorker struct {
+	repository *database.Repository
+}
+
+// NewRollupWorker creates a new rollup worker
+func NewRollupWorker(repo *database.Repository) *RollupWorker {
+	return &RollupWorker{
+		repository: repo,
+	}
+}
+
+// Run closes every bucket that has ended since the last run, tier by tier
+// Runs that closed buckets or failed are recorded in cleanup_logs.
+func (rw *RollupWorker) Run(ctx context.Context) error {
+	started := time.Now().UTC()
+	entry := &models.CleanupLog{Job: models.CleanupJobRollup, Status: models.CleanupStatusSuccess}
+
+	// A tier can only close buckets whose source buckets are all closed
+	closedBefore := map[string]time.Time{
+		models.ResolutionRaw: started.Add(-rollupGrace),
+	}
+	for _, tier := range rollupTiers {
+		watermark, aggregated, err := rw.rollup(ctx, tier, closedBefore[tier.source])
+		entry.RecordsAggregated += aggregated
+		if aggregated > 0 || err != nil {
+			result := models.CleanupTierResult{Tier: tier.name, Aggregated: aggregated}
+			if err != nil {
+				result.Error = err.Error()
+			}
+			entry.Tiers = append(entry.Tiers, result)
+		}
+		if err != nil {
+			break
+		}
+		closedBefore[tier.name] = closedBefore[tier.source]
+		if !watermark.IsZero() && watermark.Before(closedBefore[tier.name]) {
+			closedBefore[tier.name] = watermark
+		}
+	}
+
+	// Only runs that did something are logged; most runs find nothing to close
+	if len(entry.Tiers) == 0 {
+		return nil
+	}
+	entry.DurationMs = int(time.Since(started).Milliseconds())
+	return saveRunLog(ctx, rw.repository, entry)
+}
+
+// rollup aggregates every bucket of a tier that ended before closedBefore and
+// returns the tier's new watermark (zero if there is no data yet) and the
+// number of buckets written
+func (rw *RollupWorker) rollup(ctx context.Context, tier rollupTier, closedBefore time.Time) (time.Time, int64, error) {
+	end := models.BucketStart(tier.name, closedBefore)
+
+	watermark, err := rw.repository.GetRollupWatermark(ctx, tier.name)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	from := watermark
+	if from.IsZero() {
+		// First run: start from the oldest data in the source tier
+		from, err = rw.repository.GetRollupSourceStart(ctx, tier.name)
+		if err != nil || from.IsZero() {
+			return time.Time{}, 0, err
+		}
+		from = models.BucketStart(tier.name, from)
+	}
+
+	var total int64
+	for from.Before(end) {
+		to := models.BucketStart(tier.name, from.Add(tier.chunk))
+		if to.After(end) {
+			to = end
+		}
+
+		aggregated, err := tier.aggregate(rw.repository, ctx, from, to)
+		if err != nil {
+			return from, total, err
+		}
+		advanced, err := rw.repository.AdvanceRollupWatermark(ctx, tier.name, watermark, to)
+		if err != nil {
+			return from, total, err
+		}
+		total += aggregated
+		if !advanced {
+			// Rewound for a late snapshot while aggregating; the next run
+			// starts over from there
+			log.Printf("%s rollup watermark was rewound, stopping at %s", tier.name, from.Format(time.RFC3339))
+			from, err = rw.repository.GetRollupWatermark(ctx, tier.name)
+			return from, total, err
+		}
+
+		watermark = to
+		from = to
+	}
+
+	if total > 0 {
+		log.Printf("Rolled up %d %s buckets (watermark: %s)", total, tier.name, from.Format(time.RFC3339))
+	}
+	return from, total, nil
+}
@@ -0,0 +1,114 @@
+package worker
+
+import (
+	"testing"
+	"time"
+)
+
+// at parses a UTC time in the layout the tables below use
+func at(t *testing.T, value string) time.Time {
+	t.Helper()
+	parsed, err := time.Parse("2006-01-02 15:04:05", value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed
+}
+
+func TestParseCronRejectsInvalidFields(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 0 *",
+		"* * * 13 *",
+		"* * * * 8",
+		"-1 * * * *",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"a * * * *",
+		"1-b * * * *",
+		"1,,2 * * * *",
+		"@yearly",
+		"0 0 31 2 *", // Valid fields, but February never has a 31st
+	} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("ParseCron(%q) succeeded, want an error", expr)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	for _, tc := range []struct {
+		expr, from, want string
+	}{
+		// Steps, ranges and lists
+		{"*/15 * * * *", "2026-10-16 10:07:00", "2026-10-16 10:15:00"},
+		{"*/15 * * * *", "2026-10-16 10:15:00", "2026-10-16 10:30:00"},
+		{"*/15 * * * *", "2026-10-16 10:14:59", "2026-10-16 10:15:00"},
+		{"0-30/10 9 * * *", "2026-10-16 09:25:00", "2026-10-16 09:30:00"},
+		{"0-30/10 9 * * *", "2026-10-16 09:31:00", "2026-10-17 09:00:00"},
+		{"5/20 * * * *", "2026-10-16 10:26:00", "2026-10-16 10:45:00"},
+		{"5,35 * * * *", "2026-10-16 10:05:00", "2026-10-16 10:35:00"},
+		{"0 8-10,20 * * *", "2026-10-16 10:30:00", "2026-10-16 20:00:00"},
+		{"@hourly", "2026-10-16 10:30:00", "2026-10-16 11:00:00"},
+		{"@daily", "2026-10-16 10:30:00", "2026-10-17 00:00:00"},
+
+		// Month and year rollover
+		{"0 0 1 * *", "2026-01-31 12:00:00", "2026-02-01 00:00:00"},
+		{"0 0 31 * *", "2026-04-01 00:00:00", "2026-05-31 00:00:00"},
+		{"0 0 1 1 *", "2026-06-01 00:00:00", "2027-01-01 00:00:00"},
+		{"59 23 31 12 *", "2026-12-31 23:59:00", "2027-12-31 23:59:00"},
+		{"0 0 29 2 *", "2026-03-01 00:00:00", "2028-02-29 00:00:00"},
+
+		// One day field restricted: it alone decides
+		{"0 0 * * 1", "2026-10-16 00:00:00", "2026-10-19 00:00:00"},
+		{"0 0 13 * *", "2026-10-16 00:00:00", "2026-11-13 00:00:00"},
+		{"0 0 * * 7", "2026-10-16 00:00:00", "2026-10-18 00:00:00"},
+		{"0 0 * * 0", "2026-10-16 00:00:00", "2026-10-18 00:00:00"},
+
+		// Both restricted: either one matching is enough (the 13th or a Monday)
+		{"0 0 13 * 1", "2026-10-16 00:00:00", "2026-10-19 00:00:00"},
+		{"0 0 13 * 1", "2026-11-10 00:00:00", "2026-11-13 00:00:00"},
+	} {
+		schedule, err := ParseCron(tc.expr)
+		if err != nil {
+			t.Fatalf("ParseCron(%q): %v", tc.expr, err)
+		}
+		if got, want := schedule.Next(at(t, tc.from)), at(t, tc.want); !got.Equal(want) {
+			t.Errorf("%q after %s = %s, want %s", tc.expr, tc.from, got, want)
+		}
+	}
+}
+
+func TestEveryAlignsToWallClock(t *testing.T) {
+	for _, tc := range []struct {
+		interval, offset time.Duration
+		from, want       string
+	}{
+		{5 * time.Minute, 0, "2026-10-16 10:07:30", "2026-10-16 10:10:00"},
+		{5 * time.Minute, 0, "2026-10-16 10:10:00", "2026-10-16 10:15:00"},
+		{time.Hour, 2 * time.Minute, "2026-10-16 10:01:00", "2026-10-16 10:02:00"},
+		{time.Hour, 2 * time.Minute, "2026-10-16 10:02:00", "2026-10-16 11:02:00"},
+		{time.Hour, 62 * time.Minute, "2026-10-16 10:01:00", "2026-10-16 10:02:00"},
+		{24 * time.Hour, 90 * time.Minute, "2026-10-16 23:00:00", "2026-10-17 01:30:00"},
+		{24 * time.Hour, 90 * time.Minute, "2026-10-16 01:00:00", "2026-10-16 01:30:00"},
+	} {
+		schedule := Every(tc.interval, tc.offset)
+		if got, want := schedule.Next(at(t, tc.from)), at(t, tc.want); !got.Equal(want) {
+			t.Errorf("%s after %s = %s, want %s", schedule, tc.from, got, want)
+		}
+	}
+
+	// Alignment is in UTC whatever the zone of the time passed in
+	zone := time.FixedZone("UTC+5:30", 5*3600+1800)
+	from := time.Date(2026, 10, 16, 15, 40, 0, 0, zone) // 10:10 UTC
+	if got, want := Every(time.Hour, 0).Next(from), at(t, "2026-10-16 11:00:00"); !got.Equal(want) {
+		t.Errorf("hourly after %s = %s, want %s", from, got, want)
+	}
+}
@@ -0,0 +1,311 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Errors returned by Scheduler.Trigger
+var (
+	ErrUnknownJob       = errors.New("unknown job")
+	ErrJobRunning       = errors.New("job is already running")
+	ErrSchedulerStopped = errors.New("scheduler is stopped")
+	ErrNotLeader        = errors.New("this instance is not the leader")
+)
+
+// Leadership tells the scheduler whether this instance may run jobs
+// LeaderElector implements it for replicas sharing a database.
+type Leadership interface {
+	// Leading returns whether this instance is the leader, and a context that
+	// is cancelled when it stops being the leader
+	Leading() (context.Context, bool)
+}
+
+// Job is a unit of background work run by the Scheduler
+type Job struct {
+	Name       string
+	Schedule   Schedule
+	Jitter     time.Duration // Random delay of up to Jitter added to every scheduled run
+	Timeout    time.Duration // Deadline for a single run
+	RunOnStart bool          // Also run once as soon as the scheduler starts
+	Run        func(ctx context.Context) error
+}
+
+// JobStatus is the state of a registered job
+type JobStatus struct {
+	Name           string     `json:"name"`
+	Schedule       string     `json:"schedule"`
+	Running        bool       `json:"running"`
+	LastRun        *time.Time `json:"last_run,omitempty"`
+	LastDurationMs int64      `json:"last_duration_ms"`
+	LastError      string     `json:"last_error,omitempty"`
+	NextRun        *time.Time `json:"next_run,omitempty"`
+	Runs           int64      `json:"runs"`
+	Failures       int64      `json:"failures"`
+}
+
+// scheduledJob is a Job and its run state, guarded by the scheduler's mutex
+type scheduledJob struct {
+	Job
+	running      bool
+	lastRun      time.Time
+	lastDuration time.Duration
+	lastErr      error
+	nextRun      time.Time
+	runs         int64
+	failures     int64
+}
+
+// Scheduler runs jobs on their schedules, one run per job at a time
+// A run that is still going when the next one is due makes that run skip, and
+// on-demand triggers are refused while the job is running. With a Leadership,
+// runs only start while this instance is the leader and are cancelled when it
+// stops being the leader.
+type Scheduler struct {
+	leadership Leadership
+
+	mu       sync.Mutex
+	jobs     []*scheduledJob
+	started  bool
+	stopped  bool
+	ctx      context.Context
+	cancel   context.CancelFunc
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewScheduler creates an empty scheduler
+// leadership may be nil, in which case this instance always runs its jobs.
+func NewScheduler(leadership Leadership) *Scheduler {
+	// Cancelled by Stop to abort in-flight runs that miss the drain deadline
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Scheduler{
+		leadership: leadership,
+		ctx:        ctx,
+		cancel:     cancel,
+		stopChan:   make(chan struct{}),
+	}
+}
+
+// Leading reports whether this instance currently runs jobs
+func (s *Scheduler) Leading() bool {
+	if s.leadership == nil {
+		return true
+	}
+	_, leading := s.leadership.Leading()
+	return leading
+}
+
+// Register adds a job; it panics on a duplicate name or after Start
+func (s *Scheduler) Register(job Job) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.started {
+		panic("worker: Register called after Start")
+	}
+	for _, j := range s.jobs {
+		if j.Name == job.Name {
+			panic("worker: duplicate job " + job.Name)
+		}
+	}
+	s.jobs = append(s.jobs, &scheduledJob{Job: job})
+}
+
+// Start begins running every registered job on its schedule
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.started = true
+	for _, j := range s.jobs {
+		log.Printf("Scheduling job %s (%s)", j.Name, j.Schedule)
+		s.wg.Add(1)
+		go s.loop(j)
+	}
+}
+
+// Stop stops scheduling new runs, letting in-flight runs finish until ctx is
+// done and aborting them after that
+func (s *Scheduler) Stop(ctx context.Context) {
+	s.mu.Lock()
+	s.stopped = true
+	s.mu.Unlock()
+	close(s.stopChan)
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.cancel()
+		<-done
+	}
+	s.cancel()
+	log.Println("Scheduler stopped")
+}
+
+// Trigger starts a run of the named job right away, outside its schedule
+// It returns without waiting for the run to finish.
+func (s *Scheduler) Trigger(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var job *scheduledJob
+	for _, j := range s.jobs {
+		if j.Name == name {
+			job = j
+		}
+	}
+	if job == nil {
+		return fmt.Errorf("%w: %s", ErrUnknownJob, name)
+	}
+	leaderCtx, err := s.beginLocked(job)
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, name)
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.execute(job, leaderCtx, "on demand")
+	}()
+	return nil
+}
+
+// Status returns the state of every job in registration order
+func (s *Scheduler) Status() []JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make([]JobStatus, len(s.jobs))
+	for i, j := range s.jobs {
+		status := JobStatus{
+			Name:           j.Name,
+			Schedule:       j.Schedule.String(),
+			Running:        j.running,
+			LastDurationMs: j.lastDuration.Milliseconds(),
+			Runs:           j.runs,
+			Failures:       j.failures,
+		}
+		if !j.lastRun.IsZero() {
+			lastRun := j.lastRun
+			status.LastRun = &lastRun
+		}
+		if j.lastErr != nil {
+			status.LastError = j.lastErr.Error()
+		}
+		if !j.nextRun.IsZero() {
+			nextRun := j.nextRun
+			status.NextRun = &nextRun
+		}
+		statuses[i] = status
+	}
+	return statuses
+}
+
+// loop runs one job on its schedule until the scheduler stops
+func (s *Scheduler) loop(j *scheduledJob) {
+	defer s.wg.Done()
+
+	if j.RunOnStart {
+		if leaderCtx, err := s.begin(j); err == nil {
+			s.execute(j, leaderCtx, "on start")
+		}
+	}
+
+	for {
+		next := j.Schedule.Next(time.Now())
+		if next.IsZero() {
+			log.Printf("Job %s has no future runs", j.Name)
+			return
+		}
+		if j.Jitter > 0 {
+			next = next.Add(time.Duration(rand.Int63n(int64(j.Jitter))))
+		}
+		s.mu.Lock()
+		j.nextRun = next
+		s.mu.Unlock()
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-timer.C:
+			leaderCtx, err := s.begin(j)
+			switch {
+			case err == nil:
+				s.execute(j, leaderCtx, "scheduled")
+			case errors.Is(err, ErrJobRunning):
+				log.Printf("Skipping scheduled run of %s: previous run still in progress", j.Name)
+			}
+		case <-s.stopChan:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// begin marks a job as running and returns the leadership context its run is
+// bound to; it fails if the job is already running, the scheduler stopped or
+// this instance isn't the leader
+func (s *Scheduler) begin(j *scheduledJob) (context.Context, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.beginLocked(j)
+}
+
+func (s *Scheduler) beginLocked(j *scheduledJob) (context.Context, error) {
+	if s.stopped {
+		return nil, ErrSchedulerStopped
+	}
+	if j.running {
+		return nil, ErrJobRunning
+	}
+	leaderCtx := context.Background()
+	if s.leadership != nil {
+		var leading bool
+		if leaderCtx, leading = s.leadership.Leading(); !leading {
+			return nil, ErrNotLeader
+		}
+	}
+	j.running = true
+	return leaderCtx, nil
+}
+
+// execute runs a job that begin marked as running and records the outcome
+// The run is cancelled by its timeout, by the scheduler's drain deadline and
+// when leadership is lost.
+func (s *Scheduler) execute(j *scheduledJob, leaderCtx context.Context, reason string) {
+	ctx, cancel := context.WithTimeout(s.ctx, j.Timeout)
+	defer cancel()
+	stop := context.AfterFunc(leaderCtx, cancel)
+	defer stop()
+
+	started := time.Now().UTC()
+	err := j.Run(ctx)
+	duration := time.Since(started)
+
+	if err != nil {
+		log.Printf("Job %s failed (%s run, %s): %v", j.Name, reason, duration.Round(time.Millisecond), err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j.running = false
+	j.lastRun = started
+	j.lastDuration = duration
+	j.lastErr = err
+	j.runs++
+	if err != nil {
+		j.failures++
+	}
+}
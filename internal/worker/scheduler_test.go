@@ -0,0 +1,143 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// tickSchedule is due every d, so scheduled runs come quickly in tests
+type tickSchedule struct{ d time.Duration }
+
+func (s tickSchedule) Next(t time.Time) time.Time { return t.Add(s.d) }
+func (s tickSchedule) String() string             { return "every " + s.d.String() }
+
+// waitFor polls cond until it holds or a second has passed
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func jobStatus(s *Scheduler, name string) JobStatus {
+	for _, status := range s.Status() {
+		if status.Name == name {
+			return status
+		}
+	}
+	return JobStatus{}
+}
+
+func TestSchedulerSkipsOverlappingRuns(t *testing.T) {
+	var started atomic.Int32
+	release := make(chan struct{})
+
+	s := NewScheduler(nil)
+	s.Register(Job{
+		Name:     "slow",
+		Schedule: tickSchedule{10 * time.Millisecond},
+		Timeout:  time.Minute,
+		Run: func(ctx context.Context) error {
+			if started.Add(1) == 1 {
+				<-release
+			}
+			return nil
+		},
+	})
+	s.Start()
+	defer s.Stop(context.Background())
+
+	waitFor(t, "the first run", func() bool { return started.Load() == 1 })
+	// Several runs come due while the first one is still going
+	time.Sleep(100 * time.Millisecond)
+	if n := started.Load(); n != 1 {
+		t.Fatalf("%d runs started while the first was running, want 1", n)
+	}
+	if !jobStatus(s, "slow").Running {
+		t.Error("status doesn't report the job as running")
+	}
+	if err := s.Trigger("slow"); !errors.Is(err, ErrJobRunning) {
+		t.Errorf("Trigger on a running job = %v, want %v", err, ErrJobRunning)
+	}
+
+	close(release)
+	waitFor(t, "runs to resume", func() bool { return started.Load() > 1 })
+}
+
+func TestSchedulerTriggerAndStatus(t *testing.T) {
+	failure := errors.New("upstream down")
+	ran := make(chan struct{}, 1)
+
+	s := NewScheduler(nil)
+	s.Register(Job{
+		Name:     "daily",
+		Schedule: Every(24*time.Hour, 0),
+		Timeout:  time.Minute,
+		Run: func(ctx context.Context) error {
+			ran <- struct{}{}
+			return failure
+		},
+	})
+	s.Start()
+	defer s.Stop(context.Background())
+
+	if err := s.Trigger("missing"); !errors.Is(err, ErrUnknownJob) {
+		t.Errorf("Trigger on an unknown job = %v, want %v", err, ErrUnknownJob)
+	}
+
+	before := time.Now().UTC()
+	if err := s.Trigger("daily"); err != nil {
+		t.Fatalf("Trigger on an idle job: %v", err)
+	}
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Fatal("triggered job didn't run")
+	}
+	waitFor(t, "the run to be recorded", func() bool {
+		status := jobStatus(s, "daily")
+		return status.Runs == 1 && status.NextRun != nil
+	})
+
+	status := jobStatus(s, "daily")
+	if status.LastRun == nil || status.LastRun.Before(before.Add(-time.Second)) {
+		t.Errorf("last run = %v, want about %s", status.LastRun, before)
+	}
+	if want := Every(24*time.Hour, 0).Next(before); status.NextRun == nil || !status.NextRun.Equal(want) {
+		t.Errorf("next run = %v, want %s", status.NextRun, want)
+	}
+	if status.LastError != failure.Error() || status.Failures != 1 || status.Running {
+		t.Errorf("status = %+v, want one failed run with error %q", status, failure)
+	}
+	if status.Schedule != "every 24h0m0s" {
+		t.Errorf("schedule = %q", status.Schedule)
+	}
+}
+
+// notLeader never leads
+type notLeader struct{}
+
+func (notLeader) Leading() (context.Context, bool) { return nil, false }
+
+func TestSchedulerTriggerRequiresLeadership(t *testing.T) {
+	s := NewScheduler(notLeader{})
+	s.Register(Job{
+		Name:     "daily",
+		Schedule: Every(24*time.Hour, 0),
+		Timeout:  time.Minute,
+		Run:      func(ctx context.Context) error { return nil },
+	})
+	s.Start()
+	defer s.Stop(context.Background())
+
+	if err := s.Trigger("daily"); !errors.Is(err, ErrNotLeader) {
+		t.Errorf("Trigger on a follower = %v, want %v", err, ErrNotLeader)
+	}
+}
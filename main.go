@@ -1,7 +1,10 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
@@ -10,13 +13,31 @@ import (
 	"osrs-price-api/internal/api"
 	"osrs-price-api/internal/cache"
 	"osrs-price-api/internal/database"
+	"osrs-price-api/internal/models"
 	"osrs-price-api/internal/osrs"
+	"osrs-price-api/internal/spool"
 	"osrs-price-api/internal/worker"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 )
 
+// HTTP server timeouts; the write timeout leaves room for the handlers' query timeout
+const (
+	readHeaderTimeout = 5 * time.Second
+	readTimeout       = 15 * time.Second
+	writeTimeout      = 30 * time.Second
+	idleTimeout       = 2 * time.Minute
+)
+
+// leaderCheckInterval is how often a replica tries to become leader, and how
+// often the leader verifies it still holds the lock
+const leaderCheckInterval = 10 * time.Second
+
+// shutdownTimeout is how long in-flight requests and worker runs get to finish
+// on SIGTERM before they are aborted
+const shutdownTimeout = 30 * time.Second
+
 func main() {
 	// Load .env file if it exists (optional, won't fail if not found)
 	if err := godotenv.Load(); err != nil {
@@ -31,29 +52,122 @@ func main() {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 
+	sqlDB, err := db.DB()
+	if err != nil {
+		log.Fatalf("Failed to get database instance: %v", err)
+	}
+
 	// Run migrations
-	if err := database.AutoMigrate(db); err != nil {
+	if err := database.Migrate(context.Background(), db, dbConfig.MigrationsDir); err != nil {
 		log.Fatalf("Failed to run migrations: %v", err)
 	}
 
+	// Check the database is set up for DB_STORAGE (converting to TimescaleDB is a migrate command)
+	if err := database.SetupStorage(context.Background(), db, dbConfig.Storage); err != nil {
+		log.Fatalf("Failed to set up %s storage: %v", dbConfig.Storage, err)
+	}
+
 	// Initialize repository
-	repo := database.NewRepository(db)
+	repo := database.NewRepository(db, dbConfig.Storage)
 
 	// Initialize cache
 	priceCache := cache.NewPriceCache()
 
-	// Initialize OSRS client
-	osrsClient := osrs.NewClient()
+	// Initialize one price source per tracked game mode
+	// (OSRS Wiki by default, or recorded fixtures for offline use)
+	sourceConfig := osrs.LoadConfig()
+	priceSources, err := osrs.NewSources(sourceConfig)
+	if err != nil {
+		log.Fatalf("Failed to create price sources: %v", err)
+	}
+
+	// Only one replica runs background jobs: the one holding the leader lock
+	// Every instance keeps serving HTTP, and another takes over if the leader dies
+	elector := worker.NewLeaderElector(sqlDB, leaderCheckInterval)
+	elector.Start()
 
-	// Start background worker for periodic price fetching
-	// Fetch prices every 5 minutes (aligned with OSRS Wiki update frequency)
-	priceFetcher := worker.NewPriceFetcher(osrsClient, repo, 5*time.Minute)
-	priceFetcher.Start()
+	// Every background job runs on the scheduler, aligned to the wall clock
+	scheduler := worker.NewScheduler(elector)
 
-	// Start cleanup worker to manage database size
-	// Runs daily at 3 AM to delete old data and keep costs down
-	cleanupWorker := worker.NewCleanupWorker(repo, 24*time.Hour)
-	cleanupWorker.Start()
+	// Refresh the item catalog (names, limits, alch values) once a day
+	// The catalog is shared by all game modes, so it comes from the first tracked mode
+	mappingSource, ok := priceSources[models.GameModeMain]
+	if !ok {
+		mappingSource = priceSources[sourceConfig.GameModes[0]]
+	}
+	scheduler.Register(worker.Job{
+		Name:       "mapping",
+		Schedule:   worker.Every(24*time.Hour, 0),
+		Jitter:     5 * time.Minute,
+		Timeout:    10 * time.Minute,
+		RunOnStart: true,
+		Run:        worker.NewMappingFetcher(mappingSource, priceCache, repo).Run,
+	})
+
+	// Snapshots that can't be saved while the database is down are spooled
+	// to disk and replayed once it is back
+	spoolConfig := spool.LoadConfig()
+	spools := make(map[string]*spool.Spool, len(sourceConfig.GameModes))
+
+	for _, mode := range sourceConfig.GameModes {
+		source := priceSources[mode]
+
+		spools[mode], err = spool.Open(spoolConfig, mode)
+		if err != nil {
+			log.Fatalf("Failed to open %s spool: %v", mode, err)
+		}
+
+		// Fetch prices every 5 minutes (aligned with OSRS Wiki update frequency)
+		scheduler.Register(worker.Job{
+			Name:       "prices:" + mode,
+			Schedule:   worker.Every(5*time.Minute, 0),
+			Timeout:    5 * time.Minute,
+			RunOnStart: true,
+			Run:        worker.NewPriceFetcher(source, priceCache, repo, spools[mode], mode).Run,
+		})
+
+		// Record trade volumes from the average price windows
+		// The Wiki publishes each window shortly after it closes, so fetch a minute later
+		scheduler.Register(worker.Job{
+			Name:       "averages-5m:" + mode,
+			Schedule:   worker.Every(5*time.Minute, time.Minute),
+			Timeout:    5 * time.Minute,
+			RunOnStart: true,
+			Run:        worker.NewAveragePriceFetcher(source, repo, mode, models.Timestep5m).Run,
+		})
+		scheduler.Register(worker.Job{
+			Name:       "averages-1h:" + mode,
+			Schedule:   worker.Every(time.Hour, 2*time.Minute),
+			Timeout:    time.Hour,
+			RunOnStart: true,
+			Run:        worker.NewAveragePriceFetcher(source, repo, mode, models.Timestep1h).Run,
+		})
+	}
+
+	// Close hourly, daily, weekly and monthly buckets as soon as they end
+	// Catching up after a long outage can take a while, so allow a few intervals
+	scheduler.Register(worker.Job{
+		Name:       "rollup",
+		Schedule:   worker.Every(5*time.Minute, 0),
+		Timeout:    15 * time.Minute,
+		RunOnStart: true,
+		Run:        worker.NewRollupWorker(repo).Run,
+	})
+
+	// Delete data past each tier's retention, daily at 3 AM UTC by default
+	cleanupSchedule, err := worker.ParseCron(getEnv("CLEANUP_SCHEDULE", "0 3 * * *"))
+	if err != nil {
+		log.Fatalf("Invalid CLEANUP_SCHEDULE: %v", err)
+	}
+	scheduler.Register(worker.Job{
+		Name:     "cleanup",
+		Schedule: cleanupSchedule,
+		Jitter:   10 * time.Minute,
+		Timeout:  2 * time.Hour,
+		Run:      worker.NewCleanupWorker(repo).Run,
+	})
+
+	scheduler.Start()
 
 	// Initialize Gin router
 	router := gin.Default()
@@ -62,7 +176,7 @@ func main() {
 	router.Use(api.CORSMiddleware())
 
 	// Setup API routes
-	apiHandler := api.NewHandler(osrsClient, priceCache, repo)
+	apiHandler := api.NewHandler(priceSources, priceCache, repo, scheduler, spools)
 	api.SetupRoutes(router, apiHandler)
 
 	// Get port from environment or use default
@@ -75,20 +189,28 @@ func main() {
 	certFile := os.Getenv("SSL_CERT_FILE")
 	keyFile := os.Getenv("SSL_KEY_FILE")
 
-	// Setup graceful shutdown
+	server := &http.Server{
+		Addr:              ":" + port,
+		Handler:           router,
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       readTimeout,
+		WriteTimeout:      writeTimeout,
+		IdleTimeout:       idleTimeout,
+	}
+
 	go func() {
+		var err error
 		if certFile != "" && keyFile != "" {
 			log.Printf("Starting OSRS Price API server with TLS on port %s", port)
 			log.Printf("Using certificate: %s", certFile)
-			if err := router.RunTLS(":"+port, certFile, keyFile); err != nil {
-				log.Fatalf("Failed to start TLS server: %v", err)
-			}
+			err = server.ListenAndServeTLS(certFile, keyFile)
 		} else {
 			log.Printf("Starting OSRS Price API server (HTTP) on port %s", port)
 			log.Println("Note: Set SSL_CERT_FILE and SSL_KEY_FILE for HTTPS")
-			if err := router.Run(":" + port); err != nil {
-				log.Fatalf("Failed to start server: %v", err)
-			}
+			err = server.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Failed to start server: %v", err)
 		}
 	}()
 
@@ -97,8 +219,30 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
-	log.Println("Shutting down server...")
-	priceFetcher.Stop()
-	cleanupWorker.Stop()
+	log.Printf("Shutting down server (drain deadline: %s)...", shutdownTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	// Stop accepting connections and let in-flight requests complete
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("HTTP server did not drain in time: %v", err)
+	}
+
+	// Let each job finish its current run; runs still going at the
+	// deadline are cancelled so their transactions roll back
+	scheduler.Stop(ctx)
+	elector.Stop()
+
+	// Close the pool last, once nothing can use it
+	if err := sqlDB.Close(); err != nil {
+		log.Printf("Error closing database: %v", err)
+	}
 	log.Println("Server stopped")
 }
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
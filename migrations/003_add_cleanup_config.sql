@@ -21,10 +21,11 @@ CREATE TABLE IF NOT EXISTS cleanup_logs (
     duration_ms INTEGER NOT NULL,
     status VARCHAR(50) NOT NULL,
     error_message TEXT,
-    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    INDEX idx_cleanup_logs_created (created_at DESC)
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
+CREATE INDEX IF NOT EXISTS idx_cleanup_logs_created ON cleanup_logs (created_at DESC);
+
 -- Add comment
 COMMENT ON TABLE cleanup_config IS 'Configuration for automatic database cleanup';
 COMMENT ON TABLE cleanup_logs IS 'Audit log of cleanup operations';
\ No newline at end of file
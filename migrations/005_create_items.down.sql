@@ -0,0 +1,3 @@
+-- Rollback item catalog
+DROP INDEX IF EXISTS idx_items_name;
+DROP TABLE IF EXISTS items;
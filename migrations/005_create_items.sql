@@ -0,0 +1,21 @@
+-- Item catalog populated from the OSRS Wiki /mapping endpoint
+CREATE TABLE IF NOT EXISTS items (
+    id INTEGER PRIMARY KEY,
+    name TEXT NOT NULL,
+    examine TEXT,
+    members BOOLEAN DEFAULT false,
+    buy_limit INTEGER DEFAULT 0,
+    low_alch BIGINT DEFAULT 0,
+    high_alch BIGINT DEFAULT 0,
+    value BIGINT DEFAULT 0,
+    icon TEXT,
+    updated_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_items_name ON items (name);
+
+-- Comments
+COMMENT ON TABLE items IS 'Item catalog from the OSRS Wiki mapping endpoint';
+COMMENT ON COLUMN items.id IS 'OSRS item ID from the game';
+COMMENT ON COLUMN items.buy_limit IS 'Grand Exchange buy limit per 4 hours';
+COMMENT ON COLUMN items.value IS 'Store value in GP (used for alchemy values)';
@@ -0,0 +1,4 @@
+-- Rollback average price tables
+DROP INDEX IF EXISTS idx_avg_step_time;
+DROP INDEX IF EXISTS idx_avg_item_step_time;
+DROP TABLE IF EXISTS price_averages;
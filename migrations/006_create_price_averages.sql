@@ -0,0 +1,22 @@
+-- Average prices and trade volumes from the OSRS Wiki /5m and /1h endpoints
+CREATE TABLE IF NOT EXISTS price_averages (
+    id BIGSERIAL PRIMARY KEY,
+    item_id INTEGER NOT NULL,
+    timestep VARCHAR(8) NOT NULL,
+    avg_high BIGINT DEFAULT 0,
+    avg_low BIGINT DEFAULT 0,
+    high_volume BIGINT DEFAULT 0,
+    low_volume BIGINT DEFAULT 0,
+    timestamp TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_avg_item_step_time ON price_averages (item_id, timestep, timestamp);
+CREATE INDEX IF NOT EXISTS idx_avg_step_time ON price_averages (timestep, timestamp);
+
+-- Comments
+COMMENT ON TABLE price_averages IS 'Wiki-reported average prices and volumes per 5-minute or 1-hour window';
+COMMENT ON COLUMN price_averages.timestep IS 'Averaging window: 5m or 1h';
+COMMENT ON COLUMN price_averages.timestamp IS 'Start of the averaging window';
+COMMENT ON COLUMN price_averages.high_volume IS 'Number of items traded at the high (instant buy) price';
+COMMENT ON COLUMN price_averages.low_volume IS 'Number of items traded at the low (instant sell) price';
@@ -0,0 +1,23 @@
+-- Rollback game mode tracking (drops all non-main data)
+DELETE FROM price_history WHERE game_mode <> 'main';
+DELETE FROM price_history_hourly WHERE game_mode <> 'main';
+DELETE FROM price_history_daily WHERE game_mode <> 'main';
+DELETE FROM price_averages WHERE game_mode <> 'main';
+
+DROP INDEX IF EXISTS idx_item_timestamp;
+DROP INDEX IF EXISTS idx_hourly_item_time;
+DROP INDEX IF EXISTS idx_daily_item_date;
+DROP INDEX IF EXISTS idx_avg_item_step_time;
+DROP INDEX IF EXISTS idx_avg_step_time;
+
+ALTER TABLE price_history DROP COLUMN IF EXISTS game_mode;
+ALTER TABLE price_history_hourly DROP COLUMN IF EXISTS game_mode;
+ALTER TABLE price_history_daily DROP COLUMN IF EXISTS game_mode;
+ALTER TABLE price_averages DROP COLUMN IF EXISTS game_mode;
+
+CREATE INDEX idx_item_timestamp ON price_history (item_id, timestamp);
+CREATE INDEX idx_hourly_item_time ON price_history_hourly (item_id, hour_timestamp);
+ALTER TABLE price_history_daily ADD CONSTRAINT price_history_daily_item_id_day_date_key UNIQUE (item_id, day_date);
+CREATE INDEX idx_daily_item_date ON price_history_daily (item_id, day_date);
+CREATE UNIQUE INDEX idx_avg_item_step_time ON price_averages (item_id, timestep, timestamp);
+CREATE INDEX idx_avg_step_time ON price_averages (timestep, timestamp);
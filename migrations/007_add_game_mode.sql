@@ -0,0 +1,32 @@
+-- Track Deadman Mode and Fresh Start worlds alongside the main game
+-- Existing rows all came from the main game feed
+ALTER TABLE price_history
+ADD COLUMN IF NOT EXISTS game_mode VARCHAR(8) NOT NULL DEFAULT 'main';
+
+ALTER TABLE price_history_hourly
+ADD COLUMN IF NOT EXISTS game_mode VARCHAR(8) NOT NULL DEFAULT 'main';
+
+ALTER TABLE price_history_daily
+ADD COLUMN IF NOT EXISTS game_mode VARCHAR(8) NOT NULL DEFAULT 'main';
+
+ALTER TABLE price_averages
+ADD COLUMN IF NOT EXISTS game_mode VARCHAR(8) NOT NULL DEFAULT 'main';
+
+-- Rebuild indexes with game_mode as the leading column
+DROP INDEX IF EXISTS idx_item_timestamp;
+CREATE INDEX idx_item_timestamp ON price_history (game_mode, item_id, timestamp);
+
+DROP INDEX IF EXISTS idx_hourly_item_time;
+CREATE INDEX idx_hourly_item_time ON price_history_hourly (game_mode, item_id, hour_timestamp);
+
+ALTER TABLE price_history_daily DROP CONSTRAINT IF EXISTS price_history_daily_item_id_day_date_key;
+DROP INDEX IF EXISTS idx_daily_item_date;
+CREATE UNIQUE INDEX idx_daily_item_date ON price_history_daily (game_mode, item_id, day_date);
+
+DROP INDEX IF EXISTS idx_avg_item_step_time;
+CREATE UNIQUE INDEX idx_avg_item_step_time ON price_averages (game_mode, item_id, timestep, timestamp);
+
+DROP INDEX IF EXISTS idx_avg_step_time;
+CREATE INDEX idx_avg_step_time ON price_averages (game_mode, timestep, timestamp);
+
+COMMENT ON COLUMN price_history.game_mode IS 'Game mode market: main, dmm (Deadman) or fsw (Fresh Start)';
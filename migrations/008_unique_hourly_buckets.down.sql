@@ -0,0 +1,3 @@
+-- Rollback unique hourly buckets (removed duplicates are not restored)
+DROP INDEX IF EXISTS idx_hourly_item_time;
+CREATE INDEX idx_hourly_item_time ON price_history_hourly (game_mode, item_id, hour_timestamp);
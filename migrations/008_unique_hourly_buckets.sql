@@ -0,0 +1,12 @@
+-- Repeated cleanup runs re-inserted the same hours because price_history_hourly
+-- had no unique key for ON CONFLICT to match. Keep the newest row per bucket.
+DELETE FROM price_history_hourly h
+USING price_history_hourly newer
+WHERE h.game_mode = newer.game_mode
+  AND h.item_id = newer.item_id
+  AND h.hour_timestamp = newer.hour_timestamp
+  AND h.id < newer.id;
+
+-- One row per game mode, item and hour
+DROP INDEX IF EXISTS idx_hourly_item_time;
+CREATE UNIQUE INDEX idx_hourly_item_time ON price_history_hourly (game_mode, item_id, hour_timestamp);
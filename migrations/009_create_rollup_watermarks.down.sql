@@ -0,0 +1,2 @@
+-- Rollback rollup watermarks
+DROP TABLE IF EXISTS rollup_watermarks;
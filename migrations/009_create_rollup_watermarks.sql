@@ -0,0 +1,10 @@
+-- Progress of the continuous hourly and daily rollups
+CREATE TABLE IF NOT EXISTS rollup_watermarks (
+    tier VARCHAR(16) PRIMARY KEY,
+    watermark TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE
+);
+
+COMMENT ON TABLE rollup_watermarks IS 'How far each aggregate tier has been rolled up';
+COMMENT ON COLUMN rollup_watermarks.tier IS 'Aggregate tier: hourly or daily';
+COMMENT ON COLUMN rollup_watermarks.watermark IS 'End of the newest closed bucket; every bucket before it is aggregated';
@@ -0,0 +1,8 @@
+-- Rollback candle extremes
+ALTER TABLE price_history_daily
+DROP COLUMN IF EXISTS min_high,
+DROP COLUMN IF EXISTS max_low;
+
+ALTER TABLE price_history_hourly
+DROP COLUMN IF EXISTS min_high,
+DROP COLUMN IF EXISTS max_low;
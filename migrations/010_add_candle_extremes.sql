@@ -0,0 +1,25 @@
+-- Aggregates kept only the top of the high side and the bottom of the low side;
+-- candles need both extremes of each side
+ALTER TABLE price_history_hourly
+ADD COLUMN IF NOT EXISTS min_high BIGINT,
+ADD COLUMN IF NOT EXISTS max_low BIGINT;
+
+ALTER TABLE price_history_daily
+ADD COLUMN IF NOT EXISTS min_high BIGINT,
+ADD COLUMN IF NOT EXISTS max_low BIGINT;
+
+-- Best estimate for buckets aggregated before these columns existed
+UPDATE price_history_hourly
+SET min_high = LEAST(opening_high, closing_high, avg_high),
+    max_low = GREATEST(opening_low, closing_low, avg_low)
+WHERE min_high IS NULL;
+
+UPDATE price_history_daily
+SET min_high = LEAST(opening_high, closing_high, avg_high),
+    max_low = GREATEST(opening_low, closing_low, avg_low)
+WHERE min_high IS NULL;
+
+COMMENT ON COLUMN price_history_hourly.min_high IS 'Lowest high (instant buy) price in the bucket';
+COMMENT ON COLUMN price_history_hourly.max_low IS 'Highest low (instant sell) price in the bucket';
+COMMENT ON COLUMN price_history_daily.min_high IS 'Lowest high (instant buy) price in the bucket';
+COMMENT ON COLUMN price_history_daily.max_low IS 'Highest low (instant sell) price in the bucket';
@@ -0,0 +1,5 @@
+-- Rollback weekly and monthly tiers
+DROP TABLE IF EXISTS price_history_monthly;
+DROP TABLE IF EXISTS price_history_weekly;
+
+DELETE FROM rollup_watermarks WHERE tier IN ('weekly', 'monthly');
@@ -0,0 +1,56 @@
+-- Weekly and monthly aggregates, rolled up from the daily tier, for multi-year charts
+CREATE TABLE IF NOT EXISTS price_history_weekly (
+    id BIGSERIAL PRIMARY KEY,
+    game_mode VARCHAR(8) NOT NULL DEFAULT 'main',
+    item_id INTEGER NOT NULL,
+    avg_high BIGINT NOT NULL,
+    avg_low BIGINT NOT NULL,
+    max_high BIGINT NOT NULL,
+    min_high BIGINT,
+    max_low BIGINT,
+    min_low BIGINT NOT NULL,
+    opening_high BIGINT,
+    opening_low BIGINT,
+    closing_high BIGINT,
+    closing_low BIGINT,
+    total_high_volume BIGINT DEFAULT 0,
+    total_low_volume BIGINT DEFAULT 0,
+    volatility FLOAT,
+    data_points INTEGER NOT NULL,
+    week_start DATE NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_weekly_item_date ON price_history_weekly (game_mode, item_id, week_start);
+
+CREATE TABLE IF NOT EXISTS price_history_monthly (
+    id BIGSERIAL PRIMARY KEY,
+    game_mode VARCHAR(8) NOT NULL DEFAULT 'main',
+    item_id INTEGER NOT NULL,
+    avg_high BIGINT NOT NULL,
+    avg_low BIGINT NOT NULL,
+    max_high BIGINT NOT NULL,
+    min_high BIGINT,
+    max_low BIGINT,
+    min_low BIGINT NOT NULL,
+    opening_high BIGINT,
+    opening_low BIGINT,
+    closing_high BIGINT,
+    closing_low BIGINT,
+    total_high_volume BIGINT DEFAULT 0,
+    total_low_volume BIGINT DEFAULT 0,
+    volatility FLOAT,
+    data_points INTEGER NOT NULL,
+    month_start DATE NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_monthly_item_date ON price_history_monthly (game_mode, item_id, month_start);
+
+COMMENT ON TABLE price_history_weekly IS 'Weekly aggregates of the daily tier for multi-year charts';
+COMMENT ON TABLE price_history_monthly IS 'Monthly aggregates of the daily tier for multi-year charts';
+
+COMMENT ON COLUMN price_history_weekly.week_start IS 'Monday the week starts on (UTC)';
+COMMENT ON COLUMN price_history_monthly.month_start IS 'First day of the month (UTC)';
+COMMENT ON COLUMN price_history_weekly.data_points IS 'Number of hourly samples aggregated into this week';
+COMMENT ON COLUMN price_history_monthly.data_points IS 'Number of hourly samples aggregated into this month';
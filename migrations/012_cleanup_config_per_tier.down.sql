@@ -0,0 +1,12 @@
+-- Rollback per-tier cleanup configuration
+DELETE FROM cleanup_logs WHERE cutoff_date IS NULL;
+ALTER TABLE cleanup_logs ALTER COLUMN cutoff_date SET NOT NULL;
+
+ALTER TABLE cleanup_logs
+DROP COLUMN IF EXISTS tiers,
+DROP COLUMN IF EXISTS records_aggregated,
+DROP COLUMN IF EXISTS job;
+
+DELETE FROM cleanup_config WHERE tier <> 'raw';
+DROP INDEX IF EXISTS idx_cleanup_config_tier;
+ALTER TABLE cleanup_config DROP COLUMN IF EXISTS tier;
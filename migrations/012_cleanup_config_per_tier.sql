@@ -0,0 +1,34 @@
+-- Retention per storage tier instead of one global setting
+ALTER TABLE cleanup_config
+ADD COLUMN IF NOT EXISTS tier VARCHAR(16);
+
+-- The original single row configured raw data
+UPDATE cleanup_config
+SET tier = 'raw', retention_days = 8, updated_at = CURRENT_TIMESTAMP
+WHERE id = (SELECT MIN(id) FROM cleanup_config) AND tier IS NULL;
+
+DELETE FROM cleanup_config WHERE tier IS NULL;
+
+ALTER TABLE cleanup_config ALTER COLUMN tier SET NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_cleanup_config_tier ON cleanup_config (tier);
+
+INSERT INTO cleanup_config (tier, retention_days, enabled) VALUES
+    ('raw', 8, true),
+    ('hourly', 90, true),
+    ('daily', 1825, true),
+    ('weekly', 0, true),
+    ('monthly', 0, true)
+ON CONFLICT (tier) DO NOTHING;
+
+-- One log row per cleanup or rollup run, with per-tier results
+ALTER TABLE cleanup_logs
+ADD COLUMN IF NOT EXISTS job VARCHAR(16) NOT NULL DEFAULT 'cleanup',
+ADD COLUMN IF NOT EXISTS records_aggregated BIGINT NOT NULL DEFAULT 0,
+ADD COLUMN IF NOT EXISTS tiers JSONB;
+
+ALTER TABLE cleanup_logs ALTER COLUMN cutoff_date DROP NOT NULL;
+
+COMMENT ON COLUMN cleanup_config.tier IS 'Storage tier: raw, hourly, daily, weekly or monthly';
+COMMENT ON COLUMN cleanup_config.retention_days IS 'Days of data to keep; 0 keeps the tier forever';
+COMMENT ON COLUMN cleanup_logs.job IS 'Job that ran: cleanup (deletes) or rollup (aggregates)';
+COMMENT ON COLUMN cleanup_logs.tiers IS 'Per-tier results: rows aggregated or deleted, cutoff and error';
@@ -0,0 +1,4 @@
+-- Rollback price snapshots
+DROP INDEX IF EXISTS idx_price_history_snapshot;
+ALTER TABLE price_history DROP COLUMN IF EXISTS snapshot_id;
+DROP TABLE IF EXISTS price_snapshots;
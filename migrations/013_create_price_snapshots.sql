@@ -0,0 +1,23 @@
+-- One row per fetch of /latest; price_history only stores the items that changed
+CREATE TABLE IF NOT EXISTS price_snapshots (
+    id BIGSERIAL PRIMARY KEY,
+    game_mode VARCHAR(8) NOT NULL DEFAULT 'main',
+    snapshot_time TIMESTAMP WITH TIME ZONE NOT NULL,
+    fetched_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    content_hash CHAR(64) NOT NULL,
+    items_total INTEGER NOT NULL,
+    items_changed INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_snapshots_mode_time ON price_snapshots (game_mode, snapshot_time);
+
+ALTER TABLE price_history
+ADD COLUMN IF NOT EXISTS snapshot_id BIGINT REFERENCES price_snapshots (id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_price_history_snapshot ON price_history (snapshot_id);
+
+COMMENT ON TABLE price_snapshots IS 'Fetches of the Wiki /latest endpoint';
+COMMENT ON COLUMN price_snapshots.snapshot_time IS 'Start of the 5-minute Wiki update window the fetch belongs to';
+COMMENT ON COLUMN price_snapshots.content_hash IS 'SHA-256 of the fetched prices, to spot unchanged snapshots';
+COMMENT ON COLUMN price_snapshots.items_changed IS 'Number of items stored in price_history for this snapshot';
+COMMENT ON COLUMN price_history.snapshot_id IS 'Snapshot the row was stored from (NULL for backfilled and older rows)';
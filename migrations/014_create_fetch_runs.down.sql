@@ -0,0 +1,2 @@
+-- Rollback fetch runs
+DROP TABLE IF EXISTS fetch_runs;
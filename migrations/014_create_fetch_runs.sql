@@ -0,0 +1,21 @@
+-- Audit trail of every /latest fetch, including the ones that failed
+CREATE TABLE IF NOT EXISTS fetch_runs (
+    id BIGSERIAL PRIMARY KEY,
+    game_mode VARCHAR(8) NOT NULL DEFAULT 'main',
+    source VARCHAR(16) NOT NULL,
+    started_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    finished_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    items INTEGER NOT NULL DEFAULT 0,
+    bytes BIGINT NOT NULL DEFAULT 0,
+    status VARCHAR(16) NOT NULL,
+    error TEXT,
+    snapshot_id BIGINT REFERENCES price_snapshots (id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_fetch_runs_mode_started ON fetch_runs (game_mode, started_at);
+CREATE INDEX IF NOT EXISTS idx_fetch_runs_snapshot ON fetch_runs (snapshot_id);
+
+COMMENT ON TABLE fetch_runs IS 'Every fetch of the Wiki /latest endpoint and its outcome';
+COMMENT ON COLUMN fetch_runs.source IS 'Price source the fetch used (wiki or fixture)';
+COMMENT ON COLUMN fetch_runs.bytes IS 'Size of the response body before decoding';
+COMMENT ON COLUMN fetch_runs.snapshot_id IS 'Snapshot the fetch was saved as (NULL if it failed)';
@@ -0,0 +1,2 @@
+-- Rollback the legacy bound
+ALTER TABLE price_history DROP CONSTRAINT IF EXISTS price_history_legacy_bound;
@@ -0,0 +1,24 @@
+-- Bound the rows of the unpartitioned price_history ahead of migration 016,
+-- which attaches the table as the price_history_legacy partition ending at
+-- the same time
+-- The constraint is added NOT VALID, so this only holds its lock for a
+-- moment. 016 validates it under a lock that lets reads and writes carry on,
+-- and attaching then trusts it instead of scanning the whole table under an
+-- ACCESS EXCLUSIVE lock. It ends a day after today, so snapshots saved while
+-- 016 runs still fit if it runs past midnight.
+DO $$
+DECLARE
+    cutover TIMESTAMPTZ;
+BEGIN
+    IF (SELECT relkind FROM pg_class WHERE oid = 'price_history'::regclass) = 'p' THEN
+        RETURN;
+    END IF;
+
+    -- The second midnight (UTC) after now and after the newest row
+    SELECT (date_trunc('day', GREATEST(NOW(), COALESCE(MAX(timestamp), NOW())) AT TIME ZONE 'UTC') + INTERVAL '2 days') AT TIME ZONE 'UTC'
+    INTO cutover
+    FROM price_history;
+
+    EXECUTE format('ALTER TABLE price_history ADD CONSTRAINT price_history_legacy_bound CHECK (timestamp < %L) NOT VALID', cutover);
+END;
+$$;
@@ -0,0 +1,21 @@
+-- Turn price_history back into a plain table, copying the rows of every partition
+CREATE TABLE price_history_unpartitioned (LIKE price_history INCLUDING DEFAULTS INCLUDING COMMENTS);
+INSERT INTO price_history_unpartitioned SELECT * FROM price_history;
+
+ALTER SEQUENCE price_history_id_seq OWNED BY NONE;
+DROP TABLE price_history;
+DROP FUNCTION IF EXISTS ensure_price_history_partitions(TIMESTAMPTZ, TIMESTAMPTZ);
+
+ALTER TABLE price_history_unpartitioned RENAME TO price_history;
+ALTER SEQUENCE price_history_id_seq OWNED BY price_history.id;
+
+ALTER TABLE price_history ADD PRIMARY KEY (id);
+ALTER TABLE price_history ADD CONSTRAINT price_history_snapshot_id_fkey
+    FOREIGN KEY (snapshot_id) REFERENCES price_snapshots (id) ON DELETE SET NULL;
+
+CREATE INDEX idx_item_timestamp ON price_history (game_mode, item_id, timestamp);
+CREATE INDEX idx_timestamp ON price_history (timestamp);
+CREATE INDEX idx_price_history_volume ON price_history (item_id, timestamp, high_volume, low_volume);
+CREATE INDEX idx_price_history_snapshot ON price_history (snapshot_id);
+
+COMMENT ON TABLE price_history IS 'Historical price data for OSRS items';
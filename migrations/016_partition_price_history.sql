@@ -0,0 +1,95 @@
+-- Range-partition price_history by day, so cleanup drops expired days as
+-- whole partitions instead of deleting rows one by one
+-- The existing table is attached as-is as price_history_legacy, a single
+-- partition for every row before the bound added by migration 015, rather
+-- than copied; cleanup drops it like any other partition once all of it has
+-- expired.
+
+-- Creates the daily (UTC) partitions covering from_ts to to_ts that don't
+-- exist yet and returns how many it created
+-- Days already covered by another partition, such as the legacy one, are skipped.
+CREATE OR REPLACE FUNCTION ensure_price_history_partitions(from_ts TIMESTAMPTZ, to_ts TIMESTAMPTZ)
+RETURNS INTEGER AS $$
+DECLARE
+    part_day DATE := (from_ts AT TIME ZONE 'UTC')::date;
+    last_day DATE := (to_ts AT TIME ZONE 'UTC')::date;
+    part_name TEXT;
+    created INTEGER := 0;
+BEGIN
+    WHILE part_day <= last_day LOOP
+        part_name := 'price_history_p' || to_char(part_day, 'YYYYMMDD');
+        IF to_regclass(part_name) IS NULL THEN
+            BEGIN
+                EXECUTE format(
+                    'CREATE TABLE %I PARTITION OF price_history FOR VALUES FROM (%L) TO (%L)',
+                    part_name,
+                    part_day::timestamp AT TIME ZONE 'UTC',
+                    (part_day + 1)::timestamp AT TIME ZONE 'UTC'
+                );
+                created := created + 1;
+            EXCEPTION WHEN invalid_object_definition THEN
+                -- The day overlaps an existing partition
+            END;
+        END IF;
+        part_day := part_day + 1;
+    END LOOP;
+    RETURN created;
+END;
+$$ LANGUAGE plpgsql;
+
+DO $$
+DECLARE
+    cutover TIMESTAMPTZ;
+BEGIN
+    IF (SELECT relkind FROM pg_class WHERE oid = 'price_history'::regclass) = 'p' THEN
+        RETURN;
+    END IF;
+
+    -- Scans the table under a SHARE UPDATE EXCLUSIVE lock, which lets fetches
+    -- carry on; attaching trusts the constraint instead of scanning again
+    -- under the ACCESS EXCLUSIVE lock the rename takes
+    ALTER TABLE price_history VALIDATE CONSTRAINT price_history_legacy_bound;
+
+    -- The legacy partition ends where the bound does
+    SELECT substring(pg_get_expr(conbin, conrelid) FROM '''([^'']+)''')::timestamptz
+    INTO cutover
+    FROM pg_constraint
+    WHERE conrelid = 'price_history'::regclass AND conname = 'price_history_legacy_bound';
+
+    ALTER TABLE price_history RENAME TO price_history_legacy;
+    ALTER TABLE price_history_legacy DROP CONSTRAINT IF EXISTS price_history_pkey;
+    ALTER TABLE price_history_legacy ALTER COLUMN id SET NOT NULL;
+
+    -- Free the index names for the partitioned table; attaching reuses these indexes
+    ALTER INDEX IF EXISTS idx_item_timestamp RENAME TO idx_legacy_item_timestamp;
+    ALTER INDEX IF EXISTS idx_timestamp RENAME TO idx_legacy_timestamp;
+    ALTER INDEX IF EXISTS idx_price_history_volume RENAME TO idx_legacy_volume;
+    ALTER INDEX IF EXISTS idx_price_history_snapshot RENAME TO idx_legacy_snapshot;
+
+    CREATE TABLE price_history (LIKE price_history_legacy INCLUDING DEFAULTS INCLUDING COMMENTS)
+    PARTITION BY RANGE (timestamp);
+
+    -- Keep the id sequence when the legacy partition is dropped
+    ALTER TABLE price_history_legacy ALTER COLUMN id DROP DEFAULT;
+    ALTER SEQUENCE price_history_id_seq OWNED BY price_history.id;
+
+    -- Unique constraints on a partitioned table must include the partition key
+    ALTER TABLE price_history ADD PRIMARY KEY (id, timestamp);
+    ALTER TABLE price_history ADD CONSTRAINT price_history_snapshot_id_fkey
+        FOREIGN KEY (snapshot_id) REFERENCES price_snapshots (id) ON DELETE SET NULL;
+
+    CREATE INDEX idx_item_timestamp ON price_history (game_mode, item_id, timestamp);
+    CREATE INDEX idx_timestamp ON price_history (timestamp);
+    CREATE INDEX idx_price_history_volume ON price_history (item_id, timestamp, high_volume, low_volume);
+    CREATE INDEX idx_price_history_snapshot ON price_history (snapshot_id);
+
+    EXECUTE format('ALTER TABLE price_history ATTACH PARTITION price_history_legacy FOR VALUES FROM (MINVALUE) TO (%L)', cutover);
+    -- Now enforced by the partition bound
+    ALTER TABLE price_history_legacy DROP CONSTRAINT price_history_legacy_bound;
+
+    PERFORM ensure_price_history_partitions(cutover, cutover + INTERVAL '7 days');
+END;
+$$;
+
+COMMENT ON TABLE price_history IS 'Historical price data for OSRS items, partitioned by day (UTC)';
+COMMENT ON FUNCTION ensure_price_history_partitions(TIMESTAMPTZ, TIMESTAMPTZ) IS 'Creates the missing daily price_history partitions in a time range';